@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is the prefix of environment variables that set CLI flags
+// e.g., FPK_SKIP_FNPACK=true for --skip-fnpack
+const envPrefix = "FPK_"
+
+// envName returns the environment variable name for a flag
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// bindEnv sets flags that were not given on the command line from FPK_* environment variables
+// Empty variables are ignored, so unset GitHub Action inputs keep the flag defaults
+func bindEnv(cmd *cobra.Command, args []string) error {
+	var bindErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if bindErr != nil || flag.Changed || flag.Name == "help" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(flag.Name))
+		if !ok || value == "" {
+			return
+		}
+		if err := cmd.Flags().Set(flag.Name, value); err != nil {
+			bindErr = fmt.Errorf("invalid value %q for %s: %w", value, envName(flag.Name), err)
+		}
+	})
+	return bindErr
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fpk-compose-builder/internal/builder"
+)
+
+// BuildOutputs contains the results of a build exposed as GitHub Action outputs
+type BuildOutputs struct {
+	FpkFile    string
+	FpkSize    int64
+	FpkSHA256  string
+	AppDir     string
+	AppName    string
+	AppVersion string
+	AppArch    string
+}
+
+// isGitHubActions reports whether the CLI runs inside a GitHub Actions job
+func isGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// collectOutputs gathers the build outputs, hashing the .fpk file if one was produced
+func collectOutputs(b *builder.Builder, fpkFile string) (*BuildOutputs, error) {
+	outputs := &BuildOutputs{
+		FpkFile:    fpkFile,
+		AppName:    b.AppName,
+		AppVersion: b.Version,
+		AppArch:    b.GetArch(),
+	}
+
+	// The app directory of a fnpack build is removed with its work directory
+	if _, err := os.Stat(b.GetAppDir()); err == nil {
+		outputs.AppDir = b.GetAppDir()
+	}
+
+	if fpkFile == "" {
+		return outputs, nil
+	}
+
+	file, err := os.Open(fpkFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fpk file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash fpk file: %w", err)
+	}
+	outputs.FpkSize = size
+	outputs.FpkSHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return outputs, nil
+}
+
+// writeGitHubOutputs writes the outputs to $GITHUB_OUTPUT and a summary table to $GITHUB_STEP_SUMMARY
+// The single-valued outputs describe the first package, fpk-files lists the files of all packages
+func writeGitHubOutputs(results []*BuildOutputs) error {
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		first := results[0]
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "fpk-file=%s\n", first.FpkFile)
+		fmt.Fprintf(&buf, "fpk-size=%s\n", sizeString(first))
+		fmt.Fprintf(&buf, "fpk-sha256=%s\n", first.FpkSHA256)
+		fmt.Fprintf(&buf, "app-dir=%s\n", first.AppDir)
+		fmt.Fprintf(&buf, "app-name=%s\n", first.AppName)
+		fmt.Fprintf(&buf, "app-version=%s\n", first.AppVersion)
+		fmt.Fprintf(&buf, "app-arch=%s\n", first.AppArch)
+
+		// Multiline value, see "Multiline strings" in the GitHub Actions docs
+		var files []string
+		for _, result := range results {
+			if result.FpkFile != "" {
+				files = append(files, result.FpkFile)
+			}
+		}
+		delimiter := "FPK_FILES_EOF"
+		fmt.Fprintf(&buf, "fpk-files<<%s\n", delimiter)
+		for _, file := range files {
+			fmt.Fprintf(&buf, "%s\n", file)
+		}
+		fmt.Fprintf(&buf, "%s\n", delimiter)
+
+		if err := appendFile(path, buf.String()); err != nil {
+			return fmt.Errorf("failed to write GITHUB_OUTPUT: %w", err)
+		}
+	}
+
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		var buf strings.Builder
+		buf.WriteString("### FPK packages\n\n")
+		buf.WriteString("| App | Version | Arch | File | Size (bytes) | SHA-256 |\n")
+		buf.WriteString("|-----|---------|------|------|--------------|---------|\n")
+		for _, result := range results {
+			file := result.FpkFile
+			if file == "" {
+				file = result.AppDir
+			}
+			fmt.Fprintf(&buf, "| %s | %s | %s | `%s` | %s | %s |\n",
+				result.AppName, result.AppVersion, result.AppArch, file, sizeString(result), codeOrEmpty(result.FpkSHA256))
+		}
+		buf.WriteString("\n")
+		if err := appendFile(path, buf.String()); err != nil {
+			return fmt.Errorf("failed to write GITHUB_STEP_SUMMARY: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sizeString formats the fpk size, empty when no fpk file was produced
+func sizeString(outputs *BuildOutputs) string {
+	if outputs.FpkFile == "" {
+		return ""
+	}
+	return fmt.Sprintf("%d", outputs.FpkSize)
+}
+
+// codeOrEmpty formats a value as inline code, empty values stay empty
+func codeOrEmpty(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "`" + value + "`"
+}
+
+// appendFile appends content to a file, creating it if needed
+func appendFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	return err
+}
+
+// startGroup opens a collapsible log group in GitHub Actions
+func startGroup(title string) {
+	if isGitHubActions() {
+		fmt.Printf("::group::%s\n", escapeData(title))
+	}
+}
+
+// endGroup closes the current log group in GitHub Actions
+func endGroup() {
+	if isGitHubActions() {
+		fmt.Println("::endgroup::")
+	}
+}
+
+// annotateError emits an error annotation, located at the source file and line if known
+func annotateError(err error) {
+	if !isGitHubActions() {
+		return
+	}
+
+	var srcErr *builder.SourceError
+	if errors.As(err, &srcErr) && srcErr.File != "" {
+		properties := "file=" + escapeProperty(srcErr.File)
+		if srcErr.Line > 0 {
+			properties += fmt.Sprintf(",line=%d", srcErr.Line)
+		}
+		fmt.Printf("::error %s::%s\n", properties, escapeData(err.Error()))
+		return
+	}
+
+	fmt.Printf("::error::%s\n", escapeData(err.Error()))
+}
+
+// escapeData escapes a workflow command message
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a workflow command property value
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"fpk-compose-builder/internal/builder"
-	"fpk-compose-builder/internal/generator"
 )
 
 var (
@@ -19,10 +21,31 @@ var (
 	outputDir string
 	verbose   bool
 	skipFnpack bool
+	appVersion string
+	snapshot   bool
+	previous   string
+	diffJSON   bool
+	archs      []string
+	outputName string
+	buildAll   bool
+	extraVars  map[string]string
+	keepWork   bool
+	clean      bool
+	envFiles   []string
+	compFiles  []string
+	profiles   []string
+	answers    string
+	expectFile string
+	importFrom string
+	importDir  string
+	template   string
+	stackFile  string
+	force      bool
 )
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		annotateError(err)
 		os.Exit(1)
 	}
 }
@@ -34,8 +57,13 @@ var rootCmd = &cobra.Command{
 extension fields into fnOS FPK application packages.
 
 It parses the compose file, extracts metadata, generates required 
-configuration files, and optionally invokes fnpack to build the final .fpk file.`,
-	Version: version,
+configuration files, and optionally invokes fnpack to build the final .fpk file.
+
+Every flag can also be set through an FPK_ environment variable named after
+the flag, e.g. FPK_SKIP_FNPACK=true or FPK_OUTPUT_NAME={appname}_{version}.fpk.
+Flags given on the command line take precedence.`,
+	Version:           version,
+	PersistentPreRunE: bindEnv,
 }
 
 var buildCmd = &cobra.Command{
@@ -51,22 +79,343 @@ and optional wizard/UI configurations. The tool will:
 3. Process icons (resize to required dimensions)
 4. Optionally invoke fnpack to create the final .fpk file
 
+With --all, every subdirectory of the input directory containing a compose
+file is built. With several --arch values, one package is built per architecture.
+
 Example:
-  fpk-compose-builder build -i examples/Chromium -o dist/`,
+  fpk-compose-builder build -i examples/Chromium -o dist/
+  fpk-compose-builder build --all -i examples -o dist/ --arch x86_64,aarch64`,
 	RunE: runBuild,
 }
 
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate compose file and x-fnpack configuration",
+	Long: `Validate the compose file and its x-fnpack configuration without building.
+
+Reports UI entries with missing or invalid fields and localized strings
+that lack a translation for one of the configured locales.
+
+Example:
+  fpk-compose-builder validate -i examples/Chromium`,
+	SilenceUsage: true,
+	RunE:         runValidate,
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare two builds or two .fpk files",
+	Long: `Compare two packages and print a unified diff of their normalized files.
+
+Each argument is an input directory (built into a temporary directory first),
+a generated app directory or an .fpk file. Files are normalized before
+comparison: JSON files (config, wizard, UI config) are compared in canonical
+form, the manifest by its parsed keys and the compose file semantically.
+
+Example:
+  fpk-compose-builder diff dist/my-app-1.0.0.fpk apps/my-app
+  fpk-compose-builder diff --json old-dir new-dir`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE:         runDiff,
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Simulate the fnOS lifecycle against a generated package",
+	Long: `Build the package into a temporary directory and run its cmd scripts the
+way fnOS does: install_init, install_callback, main start, main status,
+upgrade_init, upgrade_callback, uninstall_init and uninstall_callback.
+
+The scripts get the fnOS environment variables (TRIM_APPDEST, TRIM_PKGVAR,
+TRIM_UID, TRIM_GID, ...) and the wizard values of --answers, a YAML map of
+wizard field to value. A docker stub on PATH records every call and reports
+containers as running.
+
+Every step must exit with 0 unless --expect sets another exit code. The
+expectations file is keyed by step name and can list docker calls a step must
+make, each matched as a substring of a recorded call:
+
+  main status:
+    exit: 0
+  uninstall_init:
+    docker:
+      - compose -f
+
+Example:
+  fpk-compose-builder test -i examples/Chromium --answers answers.yaml`,
+	SilenceUsage: true,
+	RunE:         runTest,
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the compose file as it runs after the install wizard",
+	Long: `Print the compose file of the package with the wizard values and the
+fnOS TRIM_* variables substituted, as it runs once the install wizard is answered.
+
+--answers is a YAML map of wizard field to value, fields without an answer use
+their initValue. The answers are checked against the rules of the wizard/install
+fields (required, min, max, pattern); a required field missing from the answers
+or a violated rule fails the command. TRIM_* variables default to the paths
+under /var/apps/<appname> and can be overridden in the answers file.
+
+Example:
+  fpk-compose-builder render -i examples/Chromium --answers answers.yaml`,
+	SilenceUsage: true,
+	RunE:         runRender,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Convert a CasaOS, Umbrel or Portainer app to a compose file with x-fnpack",
+	Long: `Convert an app of another app store to a compose file with x-fnpack.
+
+The app title, tagline, icon, web port and documented environment variables
+are translated to the x-fnpack manifest, install wizard and ui entries; the
+icon is downloaded to icon.png. Every field or variable without an fnOS
+equivalent is listed, review them before building.
+
+Inputs by format:
+  casaos     compose file or directory with an x-casaos section
+  umbrel     app directory with umbrel-app.yml and docker-compose.yml
+  portainer  templates JSON file, select the template with --template;
+             stack templates need their compose file with --compose
+
+Example:
+  fpk-compose-builder import --from casaos -i apps/Jellyfin -o ./jellyfin
+  fpk-compose-builder import --from portainer -i templates.json --template Registry`,
+	SilenceUsage: true,
+	RunE:         runImport,
+}
+
 func init() {
 	// Add build command to root
 	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(importCmd)
 
 	// Build command flags
 	buildCmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Input directory containing compose.yaml and icon.png")
 	buildCmd.Flags().StringVarP(&outputDir, "output", "o", "./dist", "Output directory for generated FPK structure")
 	buildCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	buildCmd.Flags().BoolVar(&skipFnpack, "skip-fnpack", false, "Skip fnpack build step (only generate directory structure)")
+	buildCmd.Flags().StringVar(&appVersion, "version", "", "Override the package version (takes precedence over x-fnpack.version_from)")
+	buildCmd.Flags().BoolVar(&snapshot, "snapshot", false, "Append build metadata to the version for snapshot builds")
+	buildCmd.Flags().StringVar(&previous, "previous", "", "Previous .fpk, app directory or manifest to check the upgrade path against")
+	buildCmd.Flags().StringSliceVar(&archs, "arch", nil, "Architectures to build (comma-separated), overrides the manifest arch")
+	buildCmd.Flags().StringVar(&outputName, "output-name", "", "File name template of the .fpk file, supports {appname}, {version} and {arch}")
+	buildCmd.Flags().BoolVar(&keepWork, "keep-workdir", false, "Keep the temporary directory the app directory is generated in before fnpack runs")
+	buildCmd.Flags().StringArrayVarP(&compFiles, "file", "f", nil, "Compose file relative to the input directory (repeatable, later files are overlays)")
+	buildCmd.Flags().StringSliceVar(&profiles, "profile", nil, "Compose profiles to enable (repeatable), overrides x-fnpack.profiles")
+	buildCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "Env file for compose variable interpolation (repeatable, default: .env in the input directory)")
+	buildCmd.Flags().BoolVar(&clean, "clean", false, "Remove the app directories and .fpk files of earlier builds of the app from the output directory")
+	buildCmd.Flags().BoolVar(&buildAll, "all", false, "Build every subdirectory of the input directory that contains a compose file")
+	buildCmd.Flags().StringToStringVar(&extraVars, "var", nil, "Extra template variables as KEY=VALUE, replaced as ${KEY} in x-fnpack content")
+
+	// Validate command flags
+	validateCmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Input directory containing compose.yaml")
+	validateCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	validateCmd.Flags().StringArrayVarP(&compFiles, "file", "f", nil, "Compose file relative to the input directory (repeatable, later files are overlays)")
+	validateCmd.Flags().StringSliceVar(&profiles, "profile", nil, "Compose profiles to enable (repeatable), overrides x-fnpack.profiles")
+	validateCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "Env file for compose variable interpolation (repeatable, default: .env in the input directory)")
+
+	// Test command flags
+	testCmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Input directory containing compose.yaml")
+	testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output, including the output of every script")
+	testCmd.Flags().StringArrayVarP(&compFiles, "file", "f", nil, "Compose file relative to the input directory (repeatable, later files are overlays)")
+	testCmd.Flags().StringSliceVar(&profiles, "profile", nil, "Compose profiles to enable (repeatable), overrides x-fnpack.profiles")
+	testCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "Env file for compose variable interpolation (repeatable, default: .env in the input directory)")
+	testCmd.Flags().StringVar(&answers, "answers", "", "YAML file with the wizard values passed to the scripts (field: value)")
+	testCmd.Flags().StringVar(&expectFile, "expect", "", "YAML file with the expected exit codes and docker calls by step")
+	testCmd.Flags().BoolVar(&keepWork, "keep-workdir", false, "Keep the temporary directory the package is installed in")
+
+	// Render command flags
+	renderCmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Input directory containing compose.yaml")
+	renderCmd.Flags().StringArrayVarP(&compFiles, "file", "f", nil, "Compose file relative to the input directory (repeatable, later files are overlays)")
+	renderCmd.Flags().StringSliceVar(&profiles, "profile", nil, "Compose profiles to enable (repeatable), overrides x-fnpack.profiles")
+	renderCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "Env file for compose variable interpolation (repeatable, default: .env in the input directory)")
+	renderCmd.Flags().StringVar(&answers, "answers", "", "YAML file with the wizard values (field: value)")
+
+	// Import command flags
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Source format: casaos, umbrel or portainer")
+	importCmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Source app: compose file or directory (casaos), app directory (umbrel) or templates JSON (portainer)")
+	importCmd.Flags().StringVarP(&importDir, "output", "o", "", "Output directory for compose.yaml and icon.png (default: ./<appname>)")
+	importCmd.Flags().StringVar(&template, "template", "", "Portainer template to convert, by title or name")
+	importCmd.Flags().StringVar(&stackFile, "compose", "", "Compose file of a Portainer stack template")
+	importCmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing compose.yaml")
+	importCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	importCmd.MarkFlagRequired("from")
+
+	// Diff command flags
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Print a JSON summary of added, removed and changed files, manifest keys and images")
 }
 
+func runDiff(cmd *cobra.Command, args []string) error {
+	diff, err := builder.DiffSources(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if diffJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+
+	if diff.IsEmpty() {
+		fmt.Println("No differences")
+		return nil
+	}
+
+	fmt.Print(diff.Unified)
+	return nil
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	// Validate input directory exists
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		return fmt.Errorf("input directory does not exist: %s", inputDir)
+	}
+
+	b := builder.NewBuilder(inputDir, outputDir, verbose)
+	b.EnvFiles = envFiles
+	b.ComposeFiles = compFiles
+	b.Profiles = profiles
+	issues, err := b.Validate()
+	if err != nil {
+		return err
+	}
+
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			fmt.Printf("✗ %s\n", issue)
+		}
+		return fmt.Errorf("validation failed: %d issue(s)", len(issues))
+	}
+
+	fmt.Printf("✓ %s is valid\n", b.AppName)
+	return nil
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	// Validate input directory exists
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		return fmt.Errorf("input directory does not exist: %s", inputDir)
+	}
+
+	values := map[string]string{}
+	if answers != "" {
+		var err error
+		if values, err = builder.LoadAnswers(answers); err != nil {
+			return err
+		}
+	}
+	expect := map[string]builder.StepExpectation{}
+	if expectFile != "" {
+		var err error
+		if expect, err = builder.LoadExpectations(expectFile); err != nil {
+			return err
+		}
+	}
+
+	b := builder.NewBuilder(inputDir, "", verbose)
+	b.EnvFiles = envFiles
+	b.ComposeFiles = compFiles
+	b.Profiles = profiles
+	b.KeepWorkDir = keepWork
+
+	results, err := b.TestLifecycle(values, expect)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if len(result.Problems) == 0 {
+			fmt.Printf("✓ %s\n", result.Step.Name())
+		} else {
+			failed++
+			fmt.Printf("✗ %s: %s\n", result.Step.Name(), strings.Join(result.Problems, "; "))
+		}
+		for _, call := range result.Docker {
+			fmt.Printf("    docker %s\n", call)
+		}
+		if result.Output != "" && (verbose || len(result.Problems) > 0) {
+			for _, line := range strings.Split(strings.TrimSuffix(result.Output, "\n"), "\n") {
+				fmt.Printf("    | %s\n", line)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("lifecycle test failed: %d of %d step(s)", failed, len(results))
+	}
+	fmt.Printf("✓ %s passed %d lifecycle steps\n", b.AppName, len(results))
+	return nil
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	// Validate input directory exists
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		return fmt.Errorf("input directory does not exist: %s", inputDir)
+	}
+
+	values := map[string]string{}
+	if answers != "" {
+		var err error
+		if values, err = builder.LoadAnswers(answers); err != nil {
+			return err
+		}
+	}
+
+	b := builder.NewBuilder(inputDir, "", false)
+	b.EnvFiles = envFiles
+	b.ComposeFiles = compFiles
+	b.Profiles = profiles
+
+	content, problems, err := b.Render(values)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "✗ %s\n", problem)
+		}
+		return fmt.Errorf("invalid answers: %d issue(s)", len(problems))
+	}
+
+	fmt.Print(string(content))
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		return fmt.Errorf("input does not exist: %s", inputDir)
+	}
+
+	result, dir, err := builder.ImportApp(builder.ImportOptions{
+		Format:      importFrom,
+		Input:       inputDir,
+		ComposeFile: stackFile,
+		Template:    template,
+		OutputDir:   importDir,
+		Force:       force,
+		Verbose:     verbose,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, field := range result.Unmapped {
+		fmt.Printf("✗ %s\n", field)
+	}
+	fmt.Printf("✓ Imported to %s (%d unmapped field(s))\n", filepath.Join(dir, "compose.yaml"), len(result.Unmapped))
+	return nil
+}
 
 func runBuild(cmd *cobra.Command, args []string) error {
 	// Validate input directory exists
@@ -79,35 +428,153 @@ func runBuild(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	inputDirs := []string{inputDir}
+	if buildAll {
+		dirs, err := findAppDirs(inputDir)
+		if err != nil {
+			return err
+		}
+		inputDirs = dirs
+	}
+
+	buildArchs := archs
+	if len(buildArchs) == 0 {
+		buildArchs = []string{""}
+	}
+
+	// Packages for several architectures need distinct file names
+	name := outputName
+	if name == "" && len(buildArchs) > 1 && !skipFnpack {
+		name = "{appname}_{version}_{arch}.fpk"
+	}
+
+	// Remove the packages and app directories of earlier builds, before any
+	// package of this run is written
+	if clean {
+		for _, dir := range inputDirs {
+			for _, arch := range buildArchs {
+				if err := newPackageBuilder(dir, arch, name).CleanOutput(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
 	if verbose {
 		fmt.Printf("Input directory: %s\n", inputDir)
 		fmt.Printf("Output directory: %s\n", outputDir)
 		fmt.Println("Starting build process...")
 	}
 
+	var results []*BuildOutputs
+	failed := 0
+	for _, dir := range inputDirs {
+		for _, arch := range buildArchs {
+			outputs, err := buildPackage(dir, arch, name)
+			if err != nil {
+				if len(inputDirs) == 1 && len(buildArchs) == 1 {
+					return fmt.Errorf("build failed: %w", err)
+				}
+				// Keep building the other packages, report all failures at the end
+				annotateError(err)
+				fmt.Fprintf(os.Stderr, "✗ %s: build failed: %v\n", dir, err)
+				failed++
+				continue
+			}
+			results = append(results, outputs)
+		}
+	}
+
+	if isGitHubActions() && len(results) > 0 {
+		if err := writeGitHubOutputs(results); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d builds failed", failed, len(inputDirs)*len(buildArchs))
+	}
+	return nil
+}
+
+// buildPackage builds a single package from dir for arch (empty keeps the manifest arch)
+func buildPackage(dir, arch, name string) (*BuildOutputs, error) {
 	// Create builder and run the build process
-	b := builder.NewBuilder(inputDir, outputDir, verbose)
+	b := newPackageBuilder(dir, arch, name)
 
+	title := "Build " + dir
+	if arch != "" {
+		title += " (" + arch + ")"
+	}
+	startGroup(title)
+	var fpkFile string
+	var err error
 	if skipFnpack {
 		// Only generate directory structure, skip fnpack
-		if err := b.Build(); err != nil {
-			return fmt.Errorf("build failed: %w", err)
-		}
-
-		fmt.Printf("✓ FPK directory structure generated at: %s/%s\n", outputDir, b.AppName)
-		printBuildSummary(b)
+		err = b.Build()
 	} else {
 		// Full build with fnpack
-		fpkFile, err := b.BuildWithFnpack()
-		if err != nil {
-			return fmt.Errorf("build failed: %w", err)
-		}
+		fpkFile, err = b.BuildWithFnpack()
+	}
+	endGroup()
+	if err != nil {
+		return nil, err
+	}
 
+	if skipFnpack {
+		fmt.Printf("✓ FPK directory structure generated at: %s\n", b.GetAppDir())
+	} else {
 		fmt.Printf("✓ FPK package built successfully: %s\n", fpkFile)
-		printBuildSummary(b)
 	}
+	printBuildSummary(b)
 
-	return nil
+	return collectOutputs(b, fpkFile)
+}
+
+// newPackageBuilder creates the builder of the package from dir for arch
+// Without fnpack the app directories of several architectures go to
+// <output>/<arch>, they would otherwise replace each other
+func newPackageBuilder(dir, arch, name string) *builder.Builder {
+	dest := outputDir
+	if skipFnpack && len(archs) > 1 && arch != "" {
+		dest = filepath.Join(outputDir, arch)
+	}
+	b := builder.NewBuilder(dir, dest, verbose)
+	b.VersionOverride = appVersion
+	b.Snapshot = snapshot
+	b.Previous = previous
+	b.Arch = arch
+	b.OutputName = name
+	b.ExtraVars = extraVars
+	b.KeepWorkDir = keepWork
+	b.EnvFiles = envFiles
+	b.ComposeFiles = compFiles
+	b.Profiles = profiles
+	return b
+}
+
+// findAppDirs returns the subdirectories of dir that contain a compose file
+func findAppDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input directory: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		appDir := filepath.Join(dir, entry.Name())
+		if _, err := builder.FindComposeFile(appDir); err == nil {
+			dirs = append(dirs, appDir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no subdirectory of %s contains a compose file", dir)
+	}
+	return dirs, nil
 }
 
 func printBuildSummary(b *builder.Builder) {
@@ -115,12 +582,10 @@ func printBuildSummary(b *builder.Builder) {
 		return
 	}
 
-	appName := b.AppName
-	version := generator.GetManifestVersion(b.Compose.XFnpack.Manifest)
-
 	fmt.Println("\nBuild Summary:")
-	fmt.Printf("  App Name:    %s\n", appName)
-	fmt.Printf("  Version:     %s\n", version)
+	fmt.Printf("  App Name:    %s\n", b.AppName)
+	fmt.Printf("  Version:     %s\n", b.Version)
+	fmt.Printf("  Arch:        %s\n", b.GetArch())
 	fmt.Printf("  Service:     %s\n", b.Variables.ServiceName)
 	if b.Variables.FirstPort != "" {
 		fmt.Printf("  Port:        %s\n", b.Variables.FirstPort)
@@ -30,14 +30,46 @@ func FindComposeFile(dir string) (string, error) {
 	return "", fmt.Errorf("no compose file found in %s (tried: %v)", dir, composeFileNames)
 }
 
+// ComposeFilePaths returns the compose files of the app: the files given with
+// ComposeFiles (relative to the input directory) or the detected compose file
+func (b *Builder) ComposeFilePaths() ([]string, error) {
+	if len(b.ComposeFiles) == 0 {
+		composePath, err := FindComposeFile(b.InputDir)
+		if err != nil {
+			return nil, err
+		}
+		return []string{composePath}, nil
+	}
+
+	paths := make([]string, 0, len(b.ComposeFiles))
+	for _, file := range b.ComposeFiles {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(b.InputDir, file)
+		}
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("compose file not found: %s", file)
+		}
+		paths = append(paths, file)
+	}
+	return paths, nil
+}
+
 // Builder handles the construction of FPK directory structure
 type Builder struct {
 	// InputDir is the directory containing compose.yaml and icon
 	InputDir string
 
 	// OutputDir is the directory where the FPK structure will be created
+	// (or where the .fpk file is placed when building with fnpack)
 	OutputDir string
 
+	// WorkDir is the directory where the app directory is generated before
+	// fnpack runs, BuildWithFnpack uses a temporary directory
+	WorkDir string
+
+	// KeepWorkDir keeps the temporary work directory after the build (--keep-workdir)
+	KeepWorkDir bool
+
 	// AppName is the application name (from manifest or service name)
 	AppName string
 
@@ -47,8 +79,60 @@ type Builder struct {
 	// Variables contains extracted template variables
 	Variables parser.Variables
 
+	// UIEntries contains the desktop launcher entries of web-facing services
+	UIEntries []parser.UIEntry
+
+	// Version is the resolved package version, consistent with the manifest
+	Version string
+
+	// VersionOverride replaces the version from compose.yaml (--version)
+	VersionOverride string
+
+	// Snapshot appends build metadata to the version (--snapshot)
+	Snapshot bool
+
+	// Arch replaces the manifest arch (--arch)
+	Arch string
+
+	// ExtraVars are user-defined template variables (--var KEY=VALUE)
+	ExtraVars map[string]string
+
+	// OutputName is the .fpk file name template (--output-name)
+	// Supports {appname}, {version} and {arch}, empty keeps the name fnpack chose
+	OutputName string
+
+	// Previous is the previous package (.fpk, app directory or manifest)
+	// the build is checked against for a valid upgrade path (--previous)
+	Previous string
+
 	// Verbose enables detailed logging
 	Verbose bool
+
+	// ComposeFiles are the compose files of the app (-f), the first is the main
+	// file and the others are overlays, defaults to the detected compose file
+	ComposeFiles []string
+
+	// Profiles are the active compose profiles (--profile), overriding x-fnpack.profiles
+	Profiles []string
+
+	// EnvFiles are the files variables are interpolated from (--env-file)
+	// Defaults to the .env file of the input directory
+	EnvFiles []string
+
+	// env holds the variables loaded from EnvFiles
+	env map[string]string
+
+	// missingEnv records unset variables that were already reported
+	missingEnv map[string]bool
+
+	// secrets are the secret wizard fields by the callback script writing them
+	secrets map[string][]parser.Secret
+
+	// hooks are the hooks of the cmd scripts, from x-fnpack.hooks and the hooks directory
+	hooks map[string]parser.ScriptHooks
+
+	// stagingDir is the directory the app directory is generated in during a build
+	stagingDir string
 }
 
 // NewBuilder creates a new Builder instance
@@ -67,39 +151,158 @@ func (b *Builder) Build() error {
 		return fmt.Errorf("failed to parse compose: %w", err)
 	}
 
-	// Step 2: Create directory structure
+	// Step 2: Fill metadata derived from the input directory and build options
+	b.applyArch()
+	if err := b.resolveVersion(); err != nil {
+		return fmt.Errorf("failed to resolve version: %w", err)
+	}
+	if err := b.resolveChangelog(); err != nil {
+		return fmt.Errorf("failed to resolve changelog: %w", err)
+	}
+	if err := b.warnLifecycle(); err != nil {
+		return fmt.Errorf("invalid lifecycle: %w", err)
+	}
+
+	// Step 3: Create directory structure in a fresh staging directory,
+	// files of earlier builds must not leak into the package
+	if err := b.createStagingDir(); err != nil {
+		return err
+	}
+	defer b.removeStagingDir()
+
 	if err := b.CreateDirectories(); err != nil {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
 
-	// Step 3: Write all files
+	// Step 4: Write all files
 	if err := b.writeAllFiles(); err != nil {
 		return fmt.Errorf("failed to write files: %w", err)
 	}
 
-	// Step 4: Process icons
+	// Step 5: Copy included files, relative bind mounts must resolve to them
+	// and included cmd scripts must parse
+	if err := b.CopyIncludes(); err != nil {
+		return fmt.Errorf("failed to copy included files: %w", err)
+	}
+	if err := b.ValidateBindMounts(); err != nil {
+		return fmt.Errorf("invalid bind mounts: %w", err)
+	}
+	if err := b.checkPackageScripts(); err != nil {
+		return fmt.Errorf("invalid cmd script: %w", err)
+	}
+
+	// Step 6: Process icons
 	if err := b.processIcons(); err != nil {
 		return fmt.Errorf("failed to process icons: %w", err)
 	}
 
+	// Step 7: Check the upgrade path from the previous package
+	if b.Previous != "" {
+		if err := b.checkPrevious(); err != nil {
+			return err
+		}
+	}
+
+	// Step 8: Replace the app directory with the staging directory
+	if err := b.swapStagingDir(); err != nil {
+		return fmt.Errorf("failed to replace app directory: %w", err)
+	}
+
 	return nil
 }
 
 
+// loadCompose loads the compose files flattened into a single file, with the
+// services outside the active profiles removed
+// escape keeps the content valid for docker compose, which interpolates it again,
+// and moves the migrate service to its profile instead of removing it
+func (b *Builder) loadCompose(composePaths []string, escape bool) ([]byte, error) {
+	data, err := parser.LoadCompose(composePaths, b.interpolateOptions(escape))
+	if err != nil {
+		return nil, newYAMLSourceError(composePaths[0], err)
+	}
+
+	data, dropped, err := parser.ApplyProfiles(data, b.Profiles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profiles: %w", err)
+	}
+
+	// The migrate service only runs in upgrade_callback
+	data, err = parser.ApplyMigrate(data, escape)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lifecycle: %w", err)
+	}
+
+	if b.Verbose && !escape {
+		for _, name := range dropped {
+			fmt.Printf("Dropped service (inactive profile): %s\n", name)
+		}
+	}
+	return data, nil
+}
+
+// composeContent returns the compose file of the package, flattened and with
+// x-fnpack removed, and the problems found moving the secrets out of the environment
+func (b *Builder) composeContent() ([]byte, []string, error) {
+	composePaths, err := b.ComposeFilePaths()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Overlays, include and extends are flattened into a single file
+	// docker compose interpolates the file again on fnOS, so "$" stays escaped
+	data, err := b.loadCompose(composePaths, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Secret wizard fields are read from files instead of the environment
+	data, warnings, err := b.applySecrets(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to apply secrets: %w", err)
+	}
+
+	// Clean the compose content (remove x-fnpack)
+	cleanContent, err := parser.CleanComposeContent(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to clean compose file: %w", err)
+	}
+
+	return cleanContent, warnings, nil
+}
+
 // parseCompose parses the compose file and extracts variables
 func (b *Builder) parseCompose() error {
-	composePath, err := FindComposeFile(b.InputDir)
+	composePaths, err := b.ComposeFilePaths()
 	if err != nil {
 		return err
 	}
+	composePath := composePaths[0]
+
+	if err := b.loadEnv(); err != nil {
+		return err
+	}
 
-	compose, err := parser.ParseComposeFile(composePath)
+	data, err := b.loadCompose(composePaths, false)
 	if err != nil {
 		return err
 	}
 
+	compose, err := parser.ParseComposeContent(data)
+	if err != nil {
+		return newYAMLSourceError(composePath, err)
+	}
+
 	b.Compose = compose
 	b.Variables = parser.ExtractVariables(compose)
+	b.Variables.Extra = b.ExtraVars
+	b.UIEntries = parser.ExtractUIEntries(compose)
+	if err := b.loadSecrets(); err != nil {
+		return err
+	}
+	if err := b.loadHooks(); err != nil {
+		return err
+	}
 
 	// Determine app name from manifest or service name
 	b.AppName = generator.GetManifestAppname(compose.XFnpack.Manifest, b.Variables)
@@ -110,15 +313,40 @@ func (b *Builder) parseCompose() error {
 		fmt.Printf("Service name: %s\n", b.Variables.ServiceName)
 		fmt.Printf("Container name: %s\n", b.Variables.ContainerName)
 		fmt.Printf("First port: %s\n", b.Variables.FirstPort)
+		for _, entry := range b.UIEntries {
+			fmt.Printf("UI entry: %s (%s://:%s%s)\n", entry.Name, entry.Protocol, entry.Port, entry.Path)
+		}
 	}
 
 	return nil
 }
 
+// resolveAppName determines the app name without a full parse of the compose file
+func (b *Builder) resolveAppName() error {
+	composePaths, err := b.ComposeFilePaths()
+	if err != nil {
+		return err
+	}
+	if err := b.loadEnv(); err != nil {
+		return err
+	}
+	data, err := b.loadCompose(composePaths, true)
+	if err != nil {
+		return err
+	}
+	compose, err := parser.ParseComposeContent(data)
+	if err != nil {
+		return newYAMLSourceError(composePaths[0], err)
+	}
+
+	b.AppName = generator.GetManifestAppname(compose.XFnpack.Manifest, parser.ExtractVariables(compose))
+	return nil
+}
+
 // CreateDirectories creates the FPK directory structure
 // Structure: app/docker, app/ui/images, cmd, config, wizard
 func (b *Builder) CreateDirectories() error {
-	appDir := filepath.Join(b.OutputDir, b.AppName)
+	appDir := b.GetAppDir()
 
 	dirs := []string{
 		filepath.Join(appDir, "app", "docker"),
@@ -141,10 +369,38 @@ func (b *Builder) CreateDirectories() error {
 }
 
 // GetAppDir returns the full path to the app directory
+// The app directory is created in WorkDir if set, otherwise in OutputDir
+// During a build it is the staging directory that replaces the app directory at the end
 func (b *Builder) GetAppDir() string {
+	if b.stagingDir != "" {
+		return b.stagingDir
+	}
+	if b.WorkDir != "" {
+		return filepath.Join(b.WorkDir, b.AppName)
+	}
 	return filepath.Join(b.OutputDir, b.AppName)
 }
 
+// GetArch returns the package architecture from the manifest
+func (b *Builder) GetArch() string {
+	return parser.GetManifestValue(b.Compose.XFnpack.Manifest, "arch", generator.ManifestDefaults["arch"])
+}
+
+// applyArch writes the --arch override to the manifest
+func (b *Builder) applyArch() {
+	if b.Arch == "" {
+		return
+	}
+	if b.Compose.XFnpack.Manifest == nil {
+		b.Compose.XFnpack.Manifest = make(map[string]interface{})
+	}
+	b.Compose.XFnpack.Manifest["arch"] = b.Arch
+
+	if b.Verbose {
+		fmt.Printf("Arch: %s\n", b.Arch)
+	}
+}
+
 // writeAllFiles writes all generated files to the FPK directory
 func (b *Builder) writeAllFiles() error {
 	writer := NewWriter(b)
@@ -191,5 +447,8 @@ func (b *Builder) writeAllFiles() error {
 // processIcons finds and processes icon files
 func (b *Builder) processIcons() error {
 	iconHandler := NewIconHandler(b)
-	return iconHandler.ProcessIcons()
+	if err := iconHandler.ProcessIcons(); err != nil {
+		return err
+	}
+	return iconHandler.ProcessUIEntryIcons()
 }
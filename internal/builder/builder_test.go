@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCompose is a minimal app used by the builder tests
+const testCompose = `x-fnpack:
+  manifest:
+    appname: demo
+    version: "1.0.0"
+services:
+  web:
+    image: nginx:1.25
+    container_name: demo-web
+    ports:
+      - "8080:80"
+`
+
+// writeFiles creates files (relative path -> content) under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestApp creates an input directory with the files and a builder writing to a
+// fresh output directory
+func newTestApp(t *testing.T, files map[string]string) *Builder {
+	t.Helper()
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, inputDir, files)
+	return NewBuilder(inputDir, filepath.Join(root, "dist"), false)
+}
+
+// exists reports whether a path exists
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
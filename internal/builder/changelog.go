@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fpk-compose-builder/internal/generator"
+)
+
+// Changelog sources of x-fnpack.changelog.from
+const (
+	ChangelogFromFile = "file"
+	ChangelogFromGit  = "git"
+)
+
+// defaultChangelogFile is the keep-a-changelog file looked up in the input directory
+const defaultChangelogFile = "CHANGELOG.md"
+
+// resolveChangelog fills the manifest changelog when it is not set explicitly
+// Sources: the current version's section of CHANGELOG.md (default, skipped
+// silently when the file does not exist) or the git commit subjects since the previous tag
+func (b *Builder) resolveChangelog() error {
+	manifest := b.Compose.XFnpack.Manifest
+	if _, ok := manifest["changelog"]; ok {
+		return nil
+	}
+
+	config := b.Compose.XFnpack.Changelog
+	var text string
+
+	switch config.From {
+	case "", ChangelogFromFile:
+		fileName := config.File
+		if fileName == "" {
+			fileName = defaultChangelogFile
+		}
+
+		content, err := os.ReadFile(filepath.Join(b.InputDir, fileName))
+		if err != nil {
+			// Only an explicitly configured changelog file is required
+			if os.IsNotExist(err) && config.From == "" && config.File == "" {
+				return nil
+			}
+			return fmt.Errorf("failed to read changelog: %w", err)
+		}
+
+		version := generator.GetManifestVersion(manifest)
+		section, ok := generator.ExtractChangelogSection(string(content), version)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: no section for version %s in %s\n", version, fileName)
+			return nil
+		}
+		text = section
+
+	case ChangelogFromGit:
+		subjects, err := commitSubjectsSincePreviousTag(b.InputDir)
+		if err != nil {
+			return err
+		}
+		for i, subject := range subjects {
+			subjects[i] = "- " + subject
+		}
+		text = strings.Join(subjects, "\n")
+
+	default:
+		return fmt.Errorf("unsupported changelog source %q (expected %s or %s)", config.From, ChangelogFromFile, ChangelogFromGit)
+	}
+
+	if text == "" {
+		return nil
+	}
+
+	if b.Compose.XFnpack.Manifest == nil {
+		b.Compose.XFnpack.Manifest = make(map[string]interface{})
+	}
+	b.Compose.XFnpack.Manifest["changelog"] = generator.FormatChangelog(text, config.MaxLength)
+
+	if b.Verbose {
+		fmt.Printf("Changelog: %s\n", b.Compose.XFnpack.Manifest["changelog"])
+	}
+
+	return nil
+}
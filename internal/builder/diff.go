@@ -0,0 +1,325 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// PackageDiff summarizes the differences between two packages
+type PackageDiff struct {
+	Files    FilesDiff    `json:"files"`
+	Manifest ManifestDiff `json:"manifest"`
+	Images   ImagesDiff   `json:"images"`
+
+	// Unified is the unified diff of the normalized files
+	Unified string `json:"-"`
+}
+
+// FilesDiff lists the added, removed and changed package paths
+type FilesDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// ManifestDiff lists the added, removed and changed manifest keys
+type ManifestDiff struct {
+	Added   map[string]string      `json:"added"`
+	Removed map[string]string      `json:"removed"`
+	Changed map[string]ValueChange `json:"changed"`
+}
+
+// ImagesDiff lists the added, removed and changed service images
+type ImagesDiff struct {
+	Added   map[string]string      `json:"added"`
+	Removed map[string]string      `json:"removed"`
+	Changed map[string]ValueChange `json:"changed"`
+}
+
+// ValueChange is the old and new value of a changed entry
+type ValueChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// IsEmpty reports whether the packages have no differences
+func (d *PackageDiff) IsEmpty() bool {
+	return len(d.Files.Added) == 0 && len(d.Files.Removed) == 0 && len(d.Files.Changed) == 0
+}
+
+// LoadOrBuildPackage loads a package for comparison
+// Input directories (containing a compose file) are built into workDir first,
+// .fpk files, app directories and manifests are loaded directly
+func LoadOrBuildPackage(source, workDir string) (*Package, error) {
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		if _, err := FindComposeFile(source); err == nil {
+			b := NewBuilder(source, workDir, false)
+			if err := b.Build(); err != nil {
+				return nil, fmt.Errorf("failed to build %s: %w", source, err)
+			}
+			pkg, err := LoadPackage(b.GetAppDir())
+			if err != nil {
+				return nil, err
+			}
+			pkg.Source = source
+			return pkg, nil
+		}
+	}
+	return LoadPackage(source)
+}
+
+// DiffPackages compares two packages after normalizing their files
+// JSON files are compared in canonical form, the manifest by its parsed keys
+// and the compose file semantically (parsed and re-serialized with sorted keys)
+func DiffPackages(oldPkg, newPkg *Package) (*PackageDiff, error) {
+	diff := &PackageDiff{
+		Files: FilesDiff{Added: []string{}, Removed: []string{}, Changed: []string{}},
+	}
+
+	paths := make(map[string]bool)
+	for filePath := range oldPkg.Files {
+		paths[filePath] = true
+	}
+	for filePath := range newPkg.Files {
+		paths[filePath] = true
+	}
+	sortedPaths := make([]string, 0, len(paths))
+	for filePath := range paths {
+		sortedPaths = append(sortedPaths, filePath)
+	}
+	sort.Strings(sortedPaths)
+
+	var unified strings.Builder
+	for _, filePath := range sortedPaths {
+		oldContent, inOld := oldPkg.Files[filePath]
+		newContent, inNew := newPkg.Files[filePath]
+
+		oldText, err := normalizeFile(filePath, oldContent, inOld)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", oldPkg.Source, filePath, err)
+		}
+		newText, err := normalizeFile(filePath, newContent, inNew)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", newPkg.Source, filePath, err)
+		}
+
+		switch {
+		case !inOld:
+			diff.Files.Added = append(diff.Files.Added, filePath)
+		case !inNew:
+			diff.Files.Removed = append(diff.Files.Removed, filePath)
+		case oldText != newText:
+			diff.Files.Changed = append(diff.Files.Changed, filePath)
+		default:
+			continue
+		}
+
+		fromFile, toFile := "a/"+filePath, "b/"+filePath
+		if !inOld {
+			fromFile = "/dev/null"
+		}
+		if !inNew {
+			toFile = "/dev/null"
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(oldText),
+			B:        difflib.SplitLines(newText),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, err
+		}
+		unified.WriteString(text)
+	}
+	diff.Unified = unified.String()
+
+	diff.Manifest = diffManifests(oldPkg.Manifest(), newPkg.Manifest())
+
+	images, err := diffImages(oldPkg, newPkg)
+	if err != nil {
+		return nil, err
+	}
+	diff.Images = images
+
+	return diff, nil
+}
+
+// normalizeFile returns the canonical text form of a package file used for comparison
+// Binary files are represented by their size and sha256
+func normalizeFile(filePath string, content []byte, exists bool) (string, error) {
+	if !exists {
+		return "", nil
+	}
+
+	switch {
+	case filePath == "manifest":
+		return normalizeManifest(content), nil
+	case filePath == "app/docker/docker-compose.yaml":
+		return normalizeYAML(content)
+	case isJSONFile(filePath):
+		if text, err := normalizeJSON(content); err == nil {
+			return text, nil
+		}
+		// Not valid JSON after all, compare as text
+	}
+
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) != -1 {
+		return fmt.Sprintf("binary file, %d bytes, sha256 %x\n", len(content), sha256.Sum256(content)), nil
+	}
+	return string(content), nil
+}
+
+// isJSONFile reports whether a package path holds JSON content
+func isJSONFile(filePath string) bool {
+	dir := path.Dir(filePath)
+	return dir == "config" || dir == "wizard" || filePath == "app/ui/config" || path.Ext(filePath) == ".json"
+}
+
+// normalizeJSON re-serializes JSON with sorted keys and fixed indentation
+func normalizeJSON(content []byte) (string, error) {
+	var data interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// normalizeYAML re-serializes YAML with sorted mapping keys
+func normalizeYAML(content []byte) (string, error) {
+	var data interface{}
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		return "", fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	out, err := yaml.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// normalizeManifest renders the parsed manifest as sorted key=value lines
+func normalizeManifest(content []byte) string {
+	manifest := ParseManifest(content)
+	keys := make([]string, 0, len(manifest))
+	for key := range manifest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s=%s\n", key, manifest[key])
+	}
+	return buf.String()
+}
+
+// diffManifests compares the parsed manifest keys of two packages
+func diffManifests(oldManifest, newManifest map[string]string) ManifestDiff {
+	diff := ManifestDiff{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]ValueChange),
+	}
+	diffMaps(oldManifest, newManifest, diff.Added, diff.Removed, diff.Changed)
+	return diff
+}
+
+// diffImages compares the service images of the compose files of two packages
+func diffImages(oldPkg, newPkg *Package) (ImagesDiff, error) {
+	diff := ImagesDiff{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]ValueChange),
+	}
+
+	oldImages, err := serviceImages(oldPkg)
+	if err != nil {
+		return diff, fmt.Errorf("%s: %w", oldPkg.Source, err)
+	}
+	newImages, err := serviceImages(newPkg)
+	if err != nil {
+		return diff, fmt.Errorf("%s: %w", newPkg.Source, err)
+	}
+
+	diffMaps(oldImages, newImages, diff.Added, diff.Removed, diff.Changed)
+	return diff, nil
+}
+
+// serviceImages maps service names to images in the compose file of a package
+func serviceImages(pkg *Package) (map[string]string, error) {
+	images := make(map[string]string)
+
+	content, ok := pkg.Compose()
+	if !ok {
+		return images, nil
+	}
+	compose, err := parser.ParseComposeContent(content)
+	if err != nil {
+		return nil, err
+	}
+	for name, service := range compose.Services {
+		images[name] = service.Image
+	}
+	return images, nil
+}
+
+// diffMaps fills added, removed and changed from two string maps
+func diffMaps(oldMap, newMap, added, removed map[string]string, changed map[string]ValueChange) {
+	for key, oldValue := range oldMap {
+		newValue, ok := newMap[key]
+		switch {
+		case !ok:
+			removed[key] = oldValue
+		case newValue != oldValue:
+			changed[key] = ValueChange{Old: oldValue, New: newValue}
+		}
+	}
+	for key, newValue := range newMap {
+		if _, ok := oldMap[key]; !ok {
+			added[key] = newValue
+		}
+	}
+}
+
+// DiffSources loads (or builds) two packages and compares them
+func DiffSources(oldSource, newSource string) (*PackageDiff, error) {
+	workDir, err := os.MkdirTemp("", "fpk-diff-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	oldPkg, err := LoadOrBuildPackage(oldSource, filepath.Join(workDir, "a"))
+	if err != nil {
+		return nil, err
+	}
+	newPkg, err := LoadOrBuildPackage(newSource, filepath.Join(workDir, "b"))
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffPackages(oldPkg, newPkg)
+}
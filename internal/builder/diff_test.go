@@ -0,0 +1,139 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeJSON(t *testing.T) {
+	a, err := normalizeJSON([]byte(`{"b": [1, 2], "a": {"y": "<x>", "x": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := normalizeJSON([]byte("{\"a\":{\"x\":true,\"y\":\"<x>\"},\n\"b\":[1,2]}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("reordered keys differ:\n%s\n%s", a, b)
+	}
+	if !strings.Contains(a, `"<x>"`) {
+		t.Errorf("HTML characters must not be escaped:\n%s", a)
+	}
+
+	if _, err := normalizeJSON([]byte(`{"a":`)); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestNormalizeYAML(t *testing.T) {
+	a, err := normalizeYAML([]byte("services:\n  web:\n    ports: [\"80:80\"]\n    image: nginx\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := normalizeYAML([]byte("services:\n    web:\n        image: \"nginx\"\n        ports:\n            - 80:80\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("reordered keys differ:\n%s\n%s", a, b)
+	}
+
+	if _, err := normalizeYAML([]byte("services: [")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestNormalizeManifest(t *testing.T) {
+	got := normalizeManifest([]byte("# comment\nversion = 1.0.0\n\nappname=demo\ninvalid line\n"))
+	if want := "appname=demo\nversion=1.0.0\n"; got != want {
+		t.Errorf("normalizeManifest = %q, want %q", got, want)
+	}
+}
+
+func TestDiffPackagesEquivalent(t *testing.T) {
+	oldPkg := testPackage("appname=demo\nversion=1.0.0\n",
+		"services:\n  web:\n    image: nginx:1.25\n    container_name: demo-web\n",
+		map[string]string{"wizard/install": `{"a": 1, "b": 2}`, "config/privilege": `{"run-as":"package"}`})
+	newPkg := testPackage("version = 1.0.0\nappname = demo\n",
+		"services:\n  web:\n    container_name: demo-web\n    image: nginx:1.25\n",
+		map[string]string{"wizard/install": "{\"b\":2,\n\"a\":1}", "config/privilege": "{\n  \"run-as\": \"package\"\n}"})
+
+	diff, err := DiffPackages(oldPkg, newPkg)
+	if err != nil {
+		t.Fatalf("DiffPackages failed: %v", err)
+	}
+	if !diff.IsEmpty() || diff.Unified != "" {
+		t.Errorf("expected no differences, got %+v\n%s", diff.Files, diff.Unified)
+	}
+}
+
+func TestDiffPackages(t *testing.T) {
+	oldPkg := testPackage("appname=demo\nversion=1.0.0\ndesc=old\n",
+		"services:\n  web:\n    image: nginx:1.25\n  db:\n    image: postgres:15\n",
+		map[string]string{
+			"cmd/main":       "#!/bin/bash\necho old\n",
+			"wizard/install": `[]`,
+			"ICON.PNG":       "\x89PNG\x00old",
+		})
+	newPkg := testPackage("appname=demo\nversion=1.1.0\nbeta=yes\n",
+		"services:\n  web:\n    image: nginx:1.27\n  cache:\n    image: redis:7\n",
+		map[string]string{
+			"cmd/main":      "#!/bin/bash\necho new\n",
+			"wizard/config": `[]`,
+			"ICON.PNG":      "\x89PNG\x00new",
+		})
+
+	diff, err := DiffPackages(oldPkg, newPkg)
+	if err != nil {
+		t.Fatalf("DiffPackages failed: %v", err)
+	}
+
+	wantFiles := FilesDiff{
+		Added:   []string{"wizard/config"},
+		Removed: []string{"wizard/install"},
+		Changed: []string{"ICON.PNG", "app/docker/docker-compose.yaml", "cmd/main", "manifest"},
+	}
+	if !reflect.DeepEqual(diff.Files, wantFiles) {
+		t.Errorf("files = %+v, want %+v", diff.Files, wantFiles)
+	}
+
+	wantManifest := ManifestDiff{
+		Added:   map[string]string{"beta": "yes"},
+		Removed: map[string]string{"desc": "old"},
+		Changed: map[string]ValueChange{"version": {Old: "1.0.0", New: "1.1.0"}},
+	}
+	if !reflect.DeepEqual(diff.Manifest, wantManifest) {
+		t.Errorf("manifest = %+v, want %+v", diff.Manifest, wantManifest)
+	}
+
+	wantImages := ImagesDiff{
+		Added:   map[string]string{"cache": "redis:7"},
+		Removed: map[string]string{"db": "postgres:15"},
+		Changed: map[string]ValueChange{"web": {Old: "nginx:1.25", New: "nginx:1.27"}},
+	}
+	if !reflect.DeepEqual(diff.Images, wantImages) {
+		t.Errorf("images = %+v, want %+v", diff.Images, wantImages)
+	}
+
+	for _, want := range []string{
+		"--- a/cmd/main\n+++ b/cmd/main\n",
+		"-echo old\n+echo new\n",
+		"--- /dev/null\n+++ b/wizard/config\n",
+		"--- a/wizard/install\n+++ /dev/null\n",
+		"-binary file, 8 bytes, sha256 ",
+	} {
+		if !strings.Contains(diff.Unified, want) {
+			t.Errorf("unified diff does not contain %q:\n%s", want, diff.Unified)
+		}
+	}
+}
+
+func TestDiffPackagesInvalidCompose(t *testing.T) {
+	oldPkg := testPackage("", "services: [", nil)
+	oldPkg.Source = "old.fpk"
+	if _, err := DiffPackages(oldPkg, testPackage("", "services: {}\n", nil)); err == nil || !strings.Contains(err.Error(), "old.fpk") {
+		t.Errorf("expected an error naming the package, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// defaultEnvFile is the env file read from the input directory when no --env-file is given
+const defaultEnvFile = ".env"
+
+// loadEnv reads the variables used for compose interpolation
+// Later files override earlier ones, a missing default .env file is skipped
+func (b *Builder) loadEnv() error {
+	b.env = make(map[string]string)
+	// Unset variables are reported once, also across loads (--clean parses first)
+	if b.missingEnv == nil {
+		b.missingEnv = make(map[string]bool)
+	}
+
+	files := b.EnvFiles
+	if len(files) == 0 {
+		defaultPath := filepath.Join(b.InputDir, defaultEnvFile)
+		if _, err := os.Stat(defaultPath); err != nil {
+			return nil
+		}
+		files = []string{defaultPath}
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read env file: %w", err)
+		}
+		env, err := parser.ParseEnvFile(content)
+		if err != nil {
+			return &SourceError{File: file, Err: err}
+		}
+		for key, value := range env {
+			b.env[key] = value
+		}
+
+		if b.Verbose {
+			fmt.Printf("Loaded env file: %s\n", file)
+		}
+	}
+
+	return nil
+}
+
+// interpolateOptions returns the options for compose interpolation
+// escape keeps the result valid for docker compose, which interpolates it again on fnOS
+func (b *Builder) interpolateOptions(escape bool) parser.InterpolateOptions {
+	return parser.InterpolateOptions{
+		Lookup:  b.lookupEnv,
+		Missing: b.warnMissingEnv,
+		Escape:  escape,
+	}
+}
+
+// lookupEnv resolves a variable for compose interpolation
+// Only env files are used, the process environment of the build host must not end up in the package
+func (b *Builder) lookupEnv(name string) (string, bool) {
+	value, ok := b.env[name]
+	return value, ok
+}
+
+// warnMissingEnv reports an unset variable once, it resolves to an empty string
+func (b *Builder) warnMissingEnv(name string) {
+	if b.missingEnv == nil {
+		b.missingEnv = make(map[string]bool)
+	}
+	if b.missingEnv[name] {
+		return
+	}
+	b.missingEnv[name] = true
+	fmt.Fprintf(os.Stderr, "Warning: variable %s is not set, defaulting to an empty string\n", name)
+}
@@ -0,0 +1,36 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildIgnoresProcessEnvironment(t *testing.T) {
+	t.Setenv("WEB_TAG", "host")
+	t.Setenv("API_TOKEN", "host-secret")
+
+	compose := strings.Replace(testCompose, "image: nginx:1.25", "image: nginx:${WEB_TAG}", 1)
+	compose = strings.Replace(compose, "    ports:\n", "    environment:\n      - TOKEN=${API_TOKEN:-unset}\n    ports:\n", 1)
+	b := newTestApp(t, map[string]string{
+		"compose.yaml": compose,
+		".env":         "WEB_TAG=1.25\n",
+	})
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(b.GetAppDir(), "app", "docker", "docker-compose.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"nginx:1.25", "TOKEN=unset"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("compose does not contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "host") {
+		t.Errorf("process environment leaked into the compose:\n%s", content)
+	}
+}
@@ -0,0 +1,46 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// SourceError is an error located in an input file
+// Line is 1-based, 0 when the position is unknown
+type SourceError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// yamlLinePattern matches the position yaml.v3 puts in its error messages
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// newYAMLSourceError wraps a yaml parse error of file with the line it reports
+// Errors located in another compose file (an overlay or included file) point to that file
+func newYAMLSourceError(file string, err error) *SourceError {
+	var fileErr *parser.ComposeFileError
+	if errors.As(err, &fileErr) {
+		file = fileErr.Path
+	}
+	srcErr := &SourceError{File: file, Err: err}
+	if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		srcErr.Line, _ = strconv.Atoi(match[1])
+	}
+	return srcErr
+}
@@ -2,9 +2,11 @@ package builder
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 // FnpackRunner handles execution of the fnpack CLI tool
@@ -17,9 +19,10 @@ func NewFnpackRunner(builder *Builder) *FnpackRunner {
 	return &FnpackRunner{builder: builder}
 }
 
-// RunFnpack executes the fnpack build command to generate the .fpk file
+// RunFnpack executes the fnpack build command to generate the .fpk file in fpkDir
+// fpkDir should be empty, so the .fpk file found afterwards is the one this run produced
 // Returns the path to the generated .fpk file on success
-func (r *FnpackRunner) RunFnpack() (string, error) {
+func (r *FnpackRunner) RunFnpack(fpkDir string) (string, error) {
 	appDir := r.builder.GetAppDir()
 
 	// Get absolute path for appDir
@@ -39,16 +42,14 @@ func (r *FnpackRunner) RunFnpack() (string, error) {
 		fmt.Printf("Building FPK from: %s\n", absAppDir)
 	}
 
-	// Get absolute path for output directory
-	absOutputDir, err := filepath.Abs(r.builder.OutputDir)
-	if err != nil {
-		return "", fmt.Errorf("failed to get absolute output path: %w", err)
+	if err := os.MkdirAll(fpkDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create fpk directory: %w", err)
 	}
 
 	// Execute fnpack build command
 	// fnpack build <app_dir> - builds the fpk in the current directory
 	cmd := exec.Command(fnpackPath, "build", absAppDir)
-	cmd.Dir = absOutputDir
+	cmd.Dir = fpkDir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -57,7 +58,7 @@ func (r *FnpackRunner) RunFnpack() (string, error) {
 	}
 
 	// Find the generated .fpk file
-	fpkFile, err := r.findFpkFile()
+	fpkFile, err := findFpkFile(fpkDir)
 	if err != nil {
 		return "", err
 	}
@@ -105,47 +106,125 @@ func (r *FnpackRunner) findFnpack() (string, error) {
 	return "", fmt.Errorf("fnpack not found in PATH or common locations (bin/fnpack)")
 }
 
-// findFpkFile searches for the generated .fpk file in the output directory
-// It looks for a file matching the app name pattern first, then falls back to any .fpk file
-func (r *FnpackRunner) findFpkFile() (string, error) {
-	appName := r.builder.AppName
-
-	// First, try to find a .fpk file matching the app name
-	// fnpack typically generates files like: appname-version.fpk or appname.fpk
-	appPattern := filepath.Join(r.builder.OutputDir, appName+"*.fpk")
-	matches, err := filepath.Glob(appPattern)
+// findFpkFile returns the single .fpk file fnpack generated in dir
+func findFpkFile(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.fpk"))
 	if err != nil {
 		return "", fmt.Errorf("failed to search for fpk file: %w", err)
 	}
 
-	if len(matches) > 0 {
-		// Return the first match for this app
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no .fpk file found in %s", dir)
+	case 1:
 		return matches[0], nil
+	default:
+		return "", fmt.Errorf("fnpack generated several .fpk files in %s: %v", dir, matches)
 	}
+}
 
-	// Fallback: search for any .fpk file (for backwards compatibility)
-	pattern := filepath.Join(r.builder.OutputDir, "*.fpk")
-	matches, err = filepath.Glob(pattern)
+// BuildWithFnpack performs the complete build process including fnpack execution
+// The app directory is generated in a temporary work directory (kept with
+// KeepWorkDir), the .fpk file is moved to OutputDir under the name from
+// OutputName (or the name fnpack chose) and its path is returned
+func (b *Builder) BuildWithFnpack() (string, error) {
+	workDir, err := os.MkdirTemp("", "fpk-build-*")
 	if err != nil {
-		return "", fmt.Errorf("failed to search for fpk file: %w", err)
+		return "", fmt.Errorf("failed to create work directory: %w", err)
 	}
-
-	if len(matches) == 0 {
-		return "", fmt.Errorf("no .fpk file found in %s", r.builder.OutputDir)
+	if b.KeepWorkDir {
+		defer fmt.Printf("Work directory kept at: %s\n", workDir)
+	} else {
+		defer os.RemoveAll(workDir)
 	}
+	b.WorkDir = workDir
 
-	// Return the first match
-	return matches[0], nil
-}
-
-// BuildWithFnpack performs the complete build process including fnpack execution
-func (b *Builder) BuildWithFnpack() (string, error) {
 	// First, run the standard build process
 	if err := b.Build(); err != nil {
 		return "", err
 	}
 
+	// Check the output name before running fnpack
+	var name string
+	if b.OutputName != "" {
+		if name, err = b.ExpandOutputName(b.OutputName); err != nil {
+			return "", err
+		}
+	}
+
 	// Then run fnpack to generate the .fpk file
 	runner := NewFnpackRunner(b)
-	return runner.RunFnpack()
+	fpkFile, err := runner.RunFnpack(filepath.Join(workDir, "fpk"))
+	if err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		name = filepath.Base(fpkFile)
+	}
+	return b.moveFpk(fpkFile, name)
+}
+
+// ExpandOutputName expands the {appname}, {version} and {arch} placeholders of an
+// output name template, appending the .fpk extension if it is missing
+func (b *Builder) ExpandOutputName(template string) (string, error) {
+	name := strings.NewReplacer(
+		"{appname}", b.AppName,
+		"{version}", b.Version,
+		"{arch}", b.GetArch(),
+	).Replace(template)
+
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("output name %q must be a file name, not a path", name)
+	}
+	if strings.ContainsAny(name, "{}") {
+		return "", fmt.Errorf("output name %q has an unknown placeholder (supported: {appname}, {version}, {arch})", name)
+	}
+	if !strings.HasSuffix(name, ".fpk") {
+		name += ".fpk"
+	}
+	return name, nil
+}
+
+// renameFile renames a file, replaced in tests to simulate a rename across filesystems
+var renameFile = os.Rename
+
+// moveFpk moves the .fpk file produced by fnpack to OutputDir/name
+// An existing file with the same name is replaced
+func (b *Builder) moveFpk(fpkFile, name string) (string, error) {
+	if err := os.MkdirAll(b.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	target := filepath.Join(b.OutputDir, name)
+	if err := renameFile(fpkFile, target); err != nil {
+		// The work directory may be on another filesystem, copy instead
+		if err := copyFile(fpkFile, target); err != nil {
+			return "", fmt.Errorf("failed to move fpk file: %w", err)
+		}
+	}
+
+	if b.Verbose {
+		fmt.Printf("Written: %s\n", target)
+	}
+	return target, nil
+}
+
+// copyFile copies src to dst, replacing dst if it exists
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
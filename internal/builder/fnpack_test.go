@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+func TestFindFpkFile(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := findFpkFile(dir); err == nil || !strings.Contains(err.Error(), "no .fpk file") {
+		t.Errorf("expected an error without an .fpk file, got %v", err)
+	}
+
+	writeFiles(t, dir, map[string]string{"demo.fpk": "", "build.log": ""})
+	got, err := findFpkFile(dir)
+	if err != nil || got != filepath.Join(dir, "demo.fpk") {
+		t.Errorf("findFpkFile = %s, %v", got, err)
+	}
+
+	writeFiles(t, dir, map[string]string{"other.fpk": ""})
+	if _, err := findFpkFile(dir); err == nil || !strings.Contains(err.Error(), "several .fpk files") {
+		t.Errorf("expected an error with several .fpk files, got %v", err)
+	}
+}
+
+func TestExpandOutputName(t *testing.T) {
+	b := NewBuilder(t.TempDir(), t.TempDir(), false)
+	b.AppName = "demo"
+	b.Version = "1.2.0"
+	b.Compose = &parser.ComposeFile{}
+	b.Compose.XFnpack.Manifest = map[string]interface{}{"arch": "aarch64"}
+
+	tests := []struct {
+		template string
+		want     string
+		err      string
+	}{
+		{"{appname}_{version}_{arch}.fpk", "demo_1.2.0_aarch64.fpk", ""},
+		{"{appname}-{version}", "demo-1.2.0.fpk", ""},
+		{"release.fpk", "release.fpk", ""},
+		{"{appname}/{version}.fpk", "", "must be a file name"},
+		{`..\{appname}.fpk`, "", "must be a file name"},
+		{"{appname}_{os}.fpk", "", "unknown placeholder"},
+	}
+
+	for _, tt := range tests {
+		got, err := b.ExpandOutputName(tt.template)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ExpandOutputName(%q) error = %v, want %q", tt.template, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandOutputName(%q) = %q, %v, want %q", tt.template, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoveFpk(t *testing.T) {
+	for _, crossDevice := range []bool{false, true} {
+		workDir := t.TempDir()
+		b := NewBuilder(t.TempDir(), filepath.Join(t.TempDir(), "dist"), false)
+		writeFiles(t, workDir, map[string]string{"fnpack.fpk": "new package"})
+		writeFiles(t, b.OutputDir, map[string]string{"demo.fpk": "old package"})
+
+		if crossDevice {
+			renameFile = func(oldpath, newpath string) error {
+				return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("invalid cross-device link")}
+			}
+		}
+		target, err := b.moveFpk(filepath.Join(workDir, "fnpack.fpk"), "demo.fpk")
+		renameFile = os.Rename
+		if err != nil {
+			t.Fatalf("moveFpk failed (cross-device %v): %v", crossDevice, err)
+		}
+
+		content, err := os.ReadFile(target)
+		if err != nil || string(content) != "new package" || target != filepath.Join(b.OutputDir, "demo.fpk") {
+			t.Errorf("moveFpk wrote %s = %q, %v (cross-device %v)", target, content, err, crossDevice)
+		}
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"src": "content", "dst": "a longer previous content"})
+
+	if err := copyFile(filepath.Join(dir, "src"), filepath.Join(dir, "dst")); err != nil {
+		t.Fatalf("copyFile failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "dst")); string(content) != "content" {
+		t.Errorf("dst = %q, want the source content", content)
+	}
+
+	if err := copyFile(filepath.Join(dir, "missing"), filepath.Join(dir, "dst")); err == nil {
+		t.Error("expected an error for a missing source")
+	}
+	if err := copyFile(filepath.Join(dir, "src"), filepath.Join(dir, "no", "dst")); err == nil {
+		t.Error("expected an error for a missing destination directory")
+	}
+}
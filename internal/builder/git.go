@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// maxGitLogCommits limits how far back the history is walked for a previous tag
+const maxGitLogCommits = 500
+
+// openRepository opens the git repository containing dir (searching parent directories)
+func openRepository(dir string) (*git.Repository, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository for %s: %w", dir, err)
+	}
+	return repo, nil
+}
+
+// tagsByCommit maps commit hashes to the names of the tags pointing at them
+// Annotated tags are resolved to their target commit
+func tagsByCommit(repo *git.Repository) (map[plumbing.Hash][]string, error) {
+	tags := make(map[plumbing.Hash][]string)
+
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// Tags of non-commit objects are irrelevant here
+				return nil
+			}
+			hash = commit.Hash
+		}
+		tags[hash] = append(tags[hash], ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// commitSubjectsSincePreviousTag returns the commit subjects from HEAD back to the previous tag
+// A tag on HEAD itself is the current release and does not stop the walk
+func commitSubjectsSincePreviousTag(dir string) ([]string, error) {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	tags, err := tagsByCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git log: %w", err)
+	}
+
+	var subjects []string
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if commit.Hash != head.Hash() && len(tags[commit.Hash]) > 0 {
+			return storer.ErrStop
+		}
+		if len(subjects) >= maxGitLogCommits {
+			return storer.ErrStop
+		}
+
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		subjects = append(subjects, subject)
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, fmt.Errorf("failed to read git log: %w", err)
+	}
+
+	return subjects, nil
+}
+
+// latestVersionTag finds the nearest version tag reachable from HEAD
+// Returns the tag name, the number of commits HEAD is ahead of it and the short HEAD hash
+func latestVersionTag(dir string, isVersion func(string) bool) (tag string, distance int, head string, err error) {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	headRef, err := repo.Head()
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	head = headRef.Hash().String()[:7]
+
+	tags, err := tagsByCommit(repo)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: headRef.Hash()})
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to read git log: %w", err)
+	}
+
+	err = iter.ForEach(func(commit *object.Commit) error {
+		for _, name := range tags[commit.Hash] {
+			if isVersion(name) {
+				tag = name
+				return storer.ErrStop
+			}
+		}
+		distance++
+		if distance >= maxGitLogCommits {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return "", 0, "", fmt.Errorf("failed to read git log: %w", err)
+	}
+
+	if tag == "" {
+		return "", 0, "", fmt.Errorf("no version tag found in the last %d commits", maxGitLogCommits)
+	}
+
+	return tag, distance, head, nil
+}
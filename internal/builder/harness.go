@@ -0,0 +1,253 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stepTimeout bounds the run time of a single lifecycle script
+const stepTimeout = 2 * time.Minute
+
+// dockerShim is the docker stub put on PATH by TestLifecycle
+// It records every call and reports all containers as running
+const dockerShim = `#!/bin/sh
+# docker stub of fpk-compose-builder test, records the calls
+printf '%s\n' "$*" >> "$FPK_DOCKER_LOG"
+case "$1" in
+inspect)
+    printf '[{"State": {"Status": "running"}}]\n'
+    ;;
+esac
+exit 0
+`
+
+// LifecycleStep is a cmd script run of the simulated fnOS lifecycle
+type LifecycleStep struct {
+	Script string
+	Args   []string
+}
+
+// Name returns the step name, the script and its arguments ("main start")
+func (s LifecycleStep) Name() string {
+	return strings.Join(append([]string{s.Script}, s.Args...), " ")
+}
+
+// Lifecycle is the order fnOS runs the cmd scripts in, from install to uninstall
+var Lifecycle = []LifecycleStep{
+	{Script: "install_init"},
+	{Script: "install_callback"},
+	{Script: "main", Args: []string{"start"}},
+	{Script: "main", Args: []string{"status"}},
+	{Script: "upgrade_init"},
+	{Script: "upgrade_callback"},
+	{Script: "uninstall_init"},
+	{Script: "uninstall_callback"},
+}
+
+// StepExpectation is the expected outcome of a lifecycle step
+type StepExpectation struct {
+	// Exit is the expected exit code (default: 0)
+	Exit int `yaml:"exit"`
+
+	// Docker lists the docker calls the step must make, each entry must be
+	// contained in one recorded call (e.g. "compose -f")
+	Docker []string `yaml:"docker"`
+}
+
+// StepResult is the outcome of a lifecycle step
+type StepResult struct {
+	Step     LifecycleStep
+	ExitCode int
+	Output   string
+	Docker   []string
+	Problems []string
+}
+
+// LoadExpectations reads the expected step outcomes, a YAML map keyed by step name
+func LoadExpectations(path string) (map[string]StepExpectation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expectations: %w", err)
+	}
+
+	var expect map[string]StepExpectation
+	if err := yaml.Unmarshal(data, &expect); err != nil {
+		return nil, &SourceError{File: path, Err: fmt.Errorf("failed to parse expectations: %w", err)}
+	}
+
+	known := make(map[string]bool)
+	for _, step := range Lifecycle {
+		known[step.Name()] = true
+	}
+	for name := range expect {
+		if !known[name] {
+			return nil, &SourceError{File: path, Err: fmt.Errorf("unknown lifecycle step %q", name)}
+		}
+	}
+	return expect, nil
+}
+
+// RuntimeEnv returns the TRIM_* variables fnOS sets for the scripts and the
+// compose file of the app, installed under root (/var/apps/<appname> when empty)
+func (b *Builder) RuntimeEnv(root string) map[string]string {
+	if root == "" {
+		root = "/var/apps/" + b.AppName
+	}
+	return map[string]string{
+		"TRIM_APPNAME": b.AppName,
+		"TRIM_APPVER":  b.Version,
+		"TRIM_APPDEST": filepath.Join(root, "target"),
+		"TRIM_PKGVAR":  filepath.Join(root, "var"),
+		"TRIM_PKGETC":  filepath.Join(root, "etc"),
+		"TRIM_PKGHOME": filepath.Join(root, "home"),
+		"TRIM_PKGTMP":  filepath.Join(root, "tmp"),
+		"TRIM_UID":     "1000",
+		"TRIM_GID":     "1000",
+	}
+}
+
+// TestLifecycle builds the package into a temporary directory and runs its cmd
+// scripts in the order of Lifecycle, with the fnOS environment variables, the
+// wizard answers and a docker stub recording the calls
+// Every step is checked against its expectation (exit code 0 by default)
+// The temporary directory is removed unless KeepWorkDir is set
+func (b *Builder) TestLifecycle(answers map[string]string, expect map[string]StepExpectation) ([]StepResult, error) {
+	root, err := os.MkdirTemp("", "fpk-test-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test directory: %w", err)
+	}
+	if b.KeepWorkDir {
+		defer fmt.Printf("Test directory kept at: %s\n", root)
+	} else {
+		defer os.RemoveAll(root)
+	}
+
+	b.WorkDir = filepath.Join(root, "build")
+	if err := b.Build(); err != nil {
+		return nil, err
+	}
+
+	// The installed app directory is the package's app/ directory,
+	// the scripts run as the current user
+	env := b.RuntimeEnv(root)
+	if uid := os.Getuid(); uid >= 0 {
+		env["TRIM_UID"] = strconv.Itoa(uid)
+		env["TRIM_GID"] = strconv.Itoa(os.Getgid())
+	}
+	appDir, err := filepath.Abs(b.GetAppDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if err := os.Symlink(filepath.Join(appDir, "app"), env["TRIM_APPDEST"]); err != nil {
+		return nil, fmt.Errorf("failed to link app directory: %w", err)
+	}
+	for _, name := range []string{"TRIM_PKGVAR", "TRIM_PKGETC", "TRIM_PKGHOME", "TRIM_PKGTMP"} {
+		if err := os.MkdirAll(env[name], 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+	}
+
+	binDir := filepath.Join(root, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create shim directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "docker"), []byte(dockerShim), 0755); err != nil {
+		return nil, fmt.Errorf("failed to write docker shim: %w", err)
+	}
+
+	base := os.Environ()
+	base = append(base, "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	base = append(base, sortedEnv(env)...)
+	base = append(base, sortedEnv(answers)...)
+
+	var results []StepResult
+	for i, step := range Lifecycle {
+		stepEnv := append([]string{}, base...)
+		if strings.HasPrefix(step.Script, "upgrade_") {
+			stepEnv = append(stepEnv, "TRIM_OLD_APPVER="+b.Version)
+		}
+		logFile := filepath.Join(root, fmt.Sprintf("docker-%d.log", i))
+		stepEnv = append(stepEnv, "FPK_DOCKER_LOG="+logFile)
+
+		result, err := runStep(filepath.Join(appDir, "cmd", step.Script), step, stepEnv, logFile)
+		if err != nil {
+			return nil, err
+		}
+		result.check(expect[step.Name()])
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+// runStep runs the script of a lifecycle step and collects its outcome
+func runStep(script string, step LifecycleStep, env []string, logFile string) (*StepResult, error) {
+	result := &StepResult{Step: step}
+	if _, err := os.Stat(script); err != nil {
+		return nil, fmt.Errorf("script cmd/%s not found", step.Script)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stepTimeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, script, step.Args...)
+	cmd.Dir = filepath.Dir(filepath.Dir(script))
+	cmd.Env = env
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	result.Output = output.String()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = exitErr.ExitCode()
+		if ctx.Err() != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("timed out after %s", stepTimeout))
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", step.Name(), err)
+	}
+
+	if data, err := os.ReadFile(logFile); err == nil {
+		result.Docker = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	return result, nil
+}
+
+// check compares the outcome of a step with its expectation
+func (r *StepResult) check(expect StepExpectation) {
+	if r.ExitCode != expect.Exit {
+		r.Problems = append(r.Problems, fmt.Sprintf("exit code %d, expected %d", r.ExitCode, expect.Exit))
+	}
+	for _, call := range expect.Docker {
+		found := false
+		for _, recorded := range r.Docker {
+			if strings.Contains(recorded, call) {
+				found = true
+			}
+		}
+		if !found {
+			r.Problems = append(r.Problems, fmt.Sprintf("expected docker call %q", call))
+		}
+	}
+}
+
+// sortedEnv formats variables as KEY=VALUE entries, sorted by name
+func sortedEnv(vars map[string]string) []string {
+	entries := make([]string, 0, len(vars))
+	for key, value := range vars {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	return entries
+}
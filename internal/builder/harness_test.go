@@ -0,0 +1,158 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stepProblems maps the names of the steps with problems to their problems
+func stepProblems(results []StepResult) map[string][]string {
+	problems := make(map[string][]string)
+	for _, result := range results {
+		if len(result.Problems) > 0 {
+			problems[result.Step.Name()] = result.Problems
+		}
+	}
+	return problems
+}
+
+func TestTestLifecycle(t *testing.T) {
+	b := newTestApp(t, map[string]string{"compose.yaml": testCompose})
+	results, err := b.TestLifecycle(nil, map[string]StepExpectation{
+		"main status": {Docker: []string{"inspect demo-web"}},
+	})
+	if err != nil {
+		t.Fatalf("TestLifecycle failed: %v", err)
+	}
+
+	if len(results) != len(Lifecycle) {
+		t.Fatalf("got %d results, want %d", len(results), len(Lifecycle))
+	}
+	for i, result := range results {
+		if result.Step.Name() != Lifecycle[i].Name() {
+			t.Errorf("step %d = %s, want %s", i, result.Step.Name(), Lifecycle[i].Name())
+		}
+	}
+	if problems := stepProblems(results); len(problems) > 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+func TestTestLifecycleExpectations(t *testing.T) {
+	// The default main script finds the container by its container_name,
+	// without one main status reports the app as stopped
+	compose := strings.Replace(testCompose, "    container_name: demo-web\n", "", 1)
+	compose = strings.Replace(compose, "x-fnpack:\n", `x-fnpack:
+  cmd/install_callback: |
+    #!/bin/sh
+    docker pull "$wizard_image"
+    exit 4
+`, 1)
+	b := newTestApp(t, map[string]string{"compose.yaml": compose})
+
+	results, err := b.TestLifecycle(map[string]string{"wizard_image": "nginx:1.27"}, map[string]StepExpectation{
+		"install_callback": {Exit: 4, Docker: []string{"pull nginx:1.27", "compose up"}},
+	})
+	if err != nil {
+		t.Fatalf("TestLifecycle failed: %v", err)
+	}
+
+	want := map[string][]string{
+		"install_callback": {`expected docker call "compose up"`},
+		"main status":      {"exit code 3, expected 0"},
+	}
+	if problems := stepProblems(results); !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
+
+func TestRunStep(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"cmd/main": "#!/bin/sh\necho \"$1 in ${PWD##*/}\"\nexit 2\n"})
+	script := filepath.Join(dir, "cmd", "main")
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(dir, "docker.log")
+	writeFiles(t, dir, map[string]string{"docker.log": "ps\ninspect demo\n"})
+
+	step := LifecycleStep{Script: "main", Args: []string{"status"}}
+	result, err := runStep(script, step, os.Environ(), logFile)
+	if err != nil {
+		t.Fatalf("runStep failed: %v", err)
+	}
+	// The script runs in the package root with its arguments
+	if result.ExitCode != 2 || result.Output != "status in "+filepath.Base(dir)+"\n" {
+		t.Errorf("result = exit %d, output %q", result.ExitCode, result.Output)
+	}
+	if !reflect.DeepEqual(result.Docker, []string{"ps", "inspect demo"}) {
+		t.Errorf("docker calls = %q", result.Docker)
+	}
+
+	if _, err := runStep(filepath.Join(dir, "cmd", "missing"), LifecycleStep{Script: "missing"}, nil, logFile); err == nil {
+		t.Error("expected an error for a missing script")
+	}
+}
+
+func TestStepResultCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int
+		expect   StepExpectation
+		problems []string
+	}{
+		{"success", 0, StepExpectation{}, nil},
+		{"unexpected failure", 1, StepExpectation{}, []string{"exit code 1, expected 0"}},
+		{"expected failure", 3, StepExpectation{Exit: 3}, nil},
+		{"unexpected success", 0, StepExpectation{Exit: 3}, []string{"exit code 0, expected 3"}},
+		{"docker calls matched", 0, StepExpectation{Docker: []string{"compose -f", "image rm nginx"}}, nil},
+		{"docker call missing", 0, StepExpectation{Docker: []string{"volume rm"}}, []string{`expected docker call "volume rm"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &StepResult{
+				ExitCode: tt.exitCode,
+				Docker:   []string{"compose -f /app/docker-compose.yaml down", "image rm nginx:1.25"},
+			}
+			result.check(tt.expect)
+			if !reflect.DeepEqual(result.Problems, tt.problems) {
+				t.Errorf("problems = %q, want %q", result.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestLoadExpectations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"expect.yaml":  "main status:\n  exit: 3\nuninstall_callback:\n  docker:\n    - image rm\n",
+		"unknown.yaml": "main restart:\n  exit: 0\n",
+		"invalid.yaml": "main status: [",
+	})
+
+	expect, err := LoadExpectations(filepath.Join(dir, "expect.yaml"))
+	if err != nil {
+		t.Fatalf("LoadExpectations failed: %v", err)
+	}
+	want := map[string]StepExpectation{
+		"main status":        {Exit: 3},
+		"uninstall_callback": {Docker: []string{"image rm"}},
+	}
+	if !reflect.DeepEqual(expect, want) {
+		t.Errorf("expectations = %+v, want %+v", expect, want)
+	}
+
+	if _, err := LoadExpectations(filepath.Join(dir, "unknown.yaml")); err == nil || !strings.Contains(err.Error(), `unknown lifecycle step "main restart"`) {
+		t.Errorf("expected an unknown step error, got %v", err)
+	}
+	if _, err := LoadExpectations(filepath.Join(dir, "invalid.yaml")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+	if _, err := LoadExpectations(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,95 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fpk-compose-builder/internal/generator"
+	"fpk-compose-builder/internal/parser"
+)
+
+// hooksDir is the directory of the input directory holding hook files,
+// named "<script>.pre.sh" and "<script>.post.sh"
+const hooksDir = "hooks"
+
+// loadHooks collects the hooks of x-fnpack.hooks and the hooks directory
+// A hook must target a cmd script and may only be defined in one place,
+// syntax errors are reported by the hook rather than the script it ends up in
+func (b *Builder) loadHooks() error {
+	b.hooks = make(map[string]parser.ScriptHooks)
+	for script, hooks := range b.Compose.XFnpack.Hooks {
+		if !isScriptName(script) {
+			return fmt.Errorf("x-fnpack.hooks: unknown script %s", script)
+		}
+		if err := checkScript("hooks."+script+".pre", hooks.Pre, b.sourceOf("hooks", script, "pre"), true); err != nil {
+			return err
+		}
+		if err := checkScript("hooks."+script+".post", hooks.Post, b.sourceOf("hooks", script, "post"), true); err != nil {
+			return err
+		}
+		b.hooks[script] = hooks
+	}
+
+	entries, err := os.ReadDir(filepath.Join(b.InputDir, hooksDir))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read hooks directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".sh")
+		script, stage, ok := strings.Cut(name, ".")
+		if !ok || (stage != "pre" && stage != "post") || !isScriptName(script) {
+			return fmt.Errorf("unexpected hook file %s/%s (expected <script>.pre.sh or <script>.post.sh)", hooksDir, entry.Name())
+		}
+
+		content, err := os.ReadFile(filepath.Join(b.InputDir, hooksDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read hook %s: %w", entry.Name(), err)
+		}
+		if err := checkScript(hooksDir+"/"+entry.Name(), string(content), scriptSource{}, true); err != nil {
+			return err
+		}
+		hooks := b.hooks[script]
+		if (stage == "pre" && hooks.Pre != "") || (stage == "post" && hooks.Post != "") {
+			return fmt.Errorf("hook %s.%s is defined in both x-fnpack.hooks and %s/%s", script, stage, hooksDir, entry.Name())
+		}
+		if stage == "pre" {
+			hooks.Pre = string(content)
+		} else {
+			hooks.Post = string(content)
+		}
+		b.hooks[script] = hooks
+	}
+
+	return nil
+}
+
+// hookSections returns the generated sections running the hooks of a script
+func (b *Builder) hookSections(script string) []string {
+	hooks := b.hooks[script]
+	var sections []string
+	if hooks.Pre != "" {
+		pre := hooks.Pre
+		if !strings.HasSuffix(pre, "\n") {
+			pre += "\n"
+		}
+		sections = append(sections, generator.MarkSection("pre hook", pre))
+	}
+	if hooks.Post != "" {
+		sections = append(sections, generator.MarkSection("post hook", generator.GeneratePostHookScript(hooks.Post)))
+	}
+	return sections
+}
+
+// isScriptName reports whether name is a cmd script of the package
+func isScriptName(name string) bool {
+	if name == "main" {
+		return true
+	}
+	_, ok := generator.LifecycleScripts[name]
+	return ok
+}
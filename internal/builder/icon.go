@@ -3,6 +3,7 @@ package builder
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -13,6 +14,8 @@ import (
 	"strings"
 
 	"github.com/disintegration/imaging"
+
+	"fpk-compose-builder/internal/generator"
 )
 
 //go:embed icons/ICON.PNG
@@ -36,23 +39,42 @@ func (h *IconHandler) ProcessIcons() error {
 	// Find icon in input directory
 	iconPath, err := h.FindIcon()
 	if err != nil {
+		// An explicitly configured icon that cannot be used is an error
+		if h.builder.Compose != nil && h.builder.Compose.XFnpack.Icon != "" {
+			return err
+		}
 		if h.builder.Verbose {
-			fmt.Printf("No icon found: %v, using default icons\n", err)
+			fmt.Printf("No icon found: %v, generating placeholder icon\n", err)
+		}
+		// No icon found, render a placeholder from the app name
+		if err := h.copyPlaceholderIcons(); err != nil {
+			if h.builder.Verbose {
+				fmt.Printf("Placeholder icon failed: %v, using default icons\n", err)
+			}
+			return h.copyDefaultIcons()
 		}
-		// No icon found, use embedded default icons
-		return h.copyDefaultIcons()
+		return nil
 	}
 
 	if h.builder.Verbose {
 		fmt.Printf("Found icon: %s\n", iconPath)
 	}
 
+	if err := validateIconStyle(h.style()); err != nil {
+		return err
+	}
+
 	// Load the source image
-	srcImg, err := imaging.Open(iconPath)
+	srcImg, err := h.openIcon(iconPath)
 	if err != nil {
 		return fmt.Errorf("failed to open icon: %w", err)
 	}
 
+	// Remove transparent borders before squaring if requested
+	if h.style().Trim {
+		srcImg = trimTransparent(srcImg)
+	}
+
 	// Pad to square if not already square
 	srcImg = h.squareImage(srcImg)
 
@@ -69,24 +91,71 @@ func (h *IconHandler) ProcessIcons() error {
 	return nil
 }
 
-// FindIcon searches for the first .png file in the input directory
+// iconBaseNames defines the preferred icon file names in priority order
+var iconBaseNames = []string{"icon", "logo"}
+
+// FindIcon locates the application icon in the input directory
+// Priority: x-fnpack.icon > icon.* > logo.* > first .png file
+// Within a base name, extensions are tried in iconExtensions order (case-insensitive)
 func (h *IconHandler) FindIcon() (string, error) {
-	entries, err := os.ReadDir(h.builder.InputDir)
+	inputDir := h.builder.InputDir
+
+	// An explicitly configured icon must exist
+	if h.builder.Compose != nil && h.builder.Compose.XFnpack.Icon != "" {
+		iconPath := filepath.Join(inputDir, h.builder.Compose.XFnpack.Icon)
+		if _, err := os.Stat(iconPath); err != nil {
+			return "", fmt.Errorf("icon %s from x-fnpack.icon not found: %w", h.builder.Compose.XFnpack.Icon, err)
+		}
+		if !isIconFile(iconPath) {
+			return "", fmt.Errorf("unsupported icon format: %s (supported: %v)", iconPath, iconExtensions)
+		}
+		return iconPath, nil
+	}
+
+	entries, err := os.ReadDir(inputDir)
 	if err != nil {
 		return "", fmt.Errorf("failed to read input directory: %w", err)
 	}
 
+	for _, baseName := range iconBaseNames {
+		for _, ext := range iconExtensions {
+			for _, entry := range entries {
+				if !entry.IsDir() && strings.EqualFold(entry.Name(), baseName+ext) {
+					return filepath.Join(inputDir, entry.Name()), nil
+				}
+			}
+		}
+	}
+
+	// Fallback: any .png file (sorted by name, as returned by os.ReadDir)
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
 		name := entry.Name()
 		if strings.HasSuffix(strings.ToLower(name), ".png") {
-			return filepath.Join(h.builder.InputDir, name), nil
+			fmt.Fprintf(os.Stderr, "Warning: using %s as app icon, name it icon.png or set x-fnpack.icon\n", name)
+			return filepath.Join(inputDir, name), nil
 		}
 	}
 
-	return "", fmt.Errorf("no .png file found in %s", h.builder.InputDir)
+	return "", fmt.Errorf("no icon file found in %s (tried x-fnpack.icon, icon.*, logo.*, *.png)", inputDir)
+}
+
+// openIcon decodes an icon file and warns when it is too small for ICON_256.PNG
+func (h *IconHandler) openIcon(iconPath string) (image.Image, error) {
+	img, err := loadIcon(iconPath)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < minIconSize && bounds.Dy() < minIconSize {
+		fmt.Fprintf(os.Stderr, "Warning: icon %s is %dx%d, smaller than %dpx; it will be upscaled\n",
+			iconPath, bounds.Dx(), bounds.Dy(), minIconSize)
+	}
+
+	return img, nil
 }
 
 // squareImage pads a non-square image to make it square, centering the original image
@@ -131,6 +200,7 @@ func (h *IconHandler) ResizeIcon(src image.Image, width, height int) image.Image
 }
 
 // CopyIcons generates and copies icons to all required locations
+// The x-fnpack.icon_style options are applied to every size
 // Generates: ICON.PNG (64x64), ICON_256.PNG (256x256)
 // Also copies to: app/ui/images/icon-64.png, app/ui/images/icon-256.png
 func (h *IconHandler) CopyIcons(srcImg image.Image) error {
@@ -150,8 +220,8 @@ func (h *IconHandler) CopyIcons(srcImg image.Image) error {
 	}
 
 	for _, icon := range icons {
-		// Resize the image
-		resized := h.ResizeIcon(srcImg, icon.width, icon.height)
+		// Resize the image and apply the icon style
+		resized := h.renderIcon(srcImg, icon.width)
 
 		// Save the resized image
 		if err := h.saveIcon(resized, icon.destPath); err != nil {
@@ -166,6 +236,50 @@ func (h *IconHandler) CopyIcons(srcImg image.Image) error {
 	return nil
 }
 
+// ProcessUIEntryIcons resizes the per-entry icons of UI entries
+// Writes: app/ui/images/<entry>-64.png, app/ui/images/<entry>-256.png
+func (h *IconHandler) ProcessUIEntryIcons() error {
+	imagesDir := filepath.Join(h.builder.GetAppDir(), "app", "ui", "images")
+
+	for _, entry := range h.builder.UIEntries {
+		if entry.Icon == "" {
+			continue
+		}
+
+		if err := generator.ValidateUIEntryName(entry.Name); err != nil {
+			return err
+		}
+		iconPath, err := h.builder.resolveInputFile(entry.Icon)
+		if errors.Is(err, errOutsideInput) {
+			return fmt.Errorf("icon %s of UI entry %s is outside the input directory", entry.Icon, entry.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open icon for UI entry %s: %w", entry.Name, err)
+		}
+		srcImg, err := h.openIcon(iconPath)
+		if err != nil {
+			return fmt.Errorf("failed to open icon for UI entry %s: %w", entry.Name, err)
+		}
+		if h.style().Trim {
+			srcImg = trimTransparent(srcImg)
+		}
+		srcImg = h.squareImage(srcImg)
+
+		for _, size := range []int{64, 256} {
+			destPath := filepath.Join(imagesDir, fmt.Sprintf("%s-%d.png", entry.Name, size))
+			if err := h.saveIcon(h.renderIcon(srcImg, size), destPath); err != nil {
+				return fmt.Errorf("failed to save icon %s: %w", destPath, err)
+			}
+
+			if h.builder.Verbose {
+				fmt.Printf("Written: %s (%dx%d)\n", destPath, size, size)
+			}
+		}
+	}
+
+	return nil
+}
+
 // saveIcon saves an image to the specified path as PNG
 func (h *IconHandler) saveIcon(img image.Image, destPath string) error {
 	// Ensure parent directory exists
@@ -0,0 +1,207 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+
+	// Register the WebP decoder for imaging.Open
+	_ "golang.org/x/image/webp"
+)
+
+// iconExtensions defines the supported icon formats in lookup priority order
+var iconExtensions = []string{".png", ".svg", ".webp", ".jpg", ".jpeg", ".ico"}
+
+// svgRasterSize is the size of the longest side SVG icons are rasterized at
+// Large enough that every generated size is a downscale
+const svgRasterSize = 1024
+
+// minIconSize is the smallest source size that is not upscaled for ICON_256.PNG
+const minIconSize = 256
+
+// isIconFile reports whether the file name has a supported icon extension
+func isIconFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, iconExt := range iconExtensions {
+		if ext == iconExt {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIcon decodes an icon file of any supported format
+// SVG is rasterized, ICO uses its largest image, other formats are decoded directly
+func loadIcon(path string) (image.Image, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		return loadSVG(path)
+	case ".ico":
+		return loadICO(path)
+	default:
+		return imaging.Open(path)
+	}
+}
+
+// loadSVG rasterizes an SVG file so that its longest side is svgRasterSize
+func loadSVG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	icon, err := oksvg.ReadIconStream(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SVG: %w", err)
+	}
+
+	width, height := svgRasterSize, svgRasterSize
+	if vw, vh := icon.ViewBox.W, icon.ViewBox.H; vw > 0 && vh > 0 {
+		if vw > vh {
+			height = int(float64(svgRasterSize) * vh / vw)
+		} else {
+			width = int(float64(svgRasterSize) * vw / vh)
+		}
+	}
+
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1.0)
+
+	return img, nil
+}
+
+// maxICOSize is the largest width and height of an ICO bitmap
+// The directory stores sizes up to 256, larger bitmaps are malformed
+const maxICOSize = 1024
+
+// icoEntry is a directory entry of an ICO file
+type icoEntry struct {
+	Width      uint8
+	Height     uint8
+	ColorCount uint8
+	Reserved   uint8
+	Planes     uint16
+	BitCount   uint16
+	Size       uint32
+	Offset     uint32
+}
+
+// loadICO decodes the largest image of an ICO file
+// Entries are either embedded PNGs or 24/32-bit BMP bitmaps
+func loadICO(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bytes.NewReader(data)
+	var header struct {
+		Reserved uint16
+		Type     uint16
+		Count    uint16
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil || header.Type != 1 || header.Count == 0 {
+		return nil, fmt.Errorf("invalid ICO file")
+	}
+
+	var best icoEntry
+	bestSize := -1
+	for i := 0; i < int(header.Count); i++ {
+		var entry icoEntry
+		if err := binary.Read(reader, binary.LittleEndian, &entry); err != nil {
+			return nil, fmt.Errorf("invalid ICO directory: %w", err)
+		}
+		// A width of 0 means 256
+		size := int(entry.Width)
+		if size == 0 {
+			size = 256
+		}
+		if size > bestSize {
+			best, bestSize = entry, size
+		}
+	}
+
+	end := uint64(best.Offset) + uint64(best.Size)
+	if end > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid ICO image offset")
+	}
+	imgData := data[best.Offset:end]
+
+	if bytes.HasPrefix(imgData, []byte("\x89PNG")) {
+		return png.Decode(bytes.NewReader(imgData))
+	}
+	return decodeICOBitmap(imgData)
+}
+
+// decodeICOBitmap decodes a headerless BMP (DIB) image stored in an ICO file
+// The DIB height covers both the color bitmap and the 1-bit AND mask
+func decodeICOBitmap(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, fmt.Errorf("invalid ICO bitmap")
+	}
+
+	headerSize := binary.LittleEndian.Uint32(data[0:4])
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:16]))
+
+	if bitCount != 24 && bitCount != 32 {
+		return nil, fmt.Errorf("unsupported ICO bitmap depth: %d bits", bitCount)
+	}
+	if width <= 0 || height <= 0 || width > maxICOSize || height > maxICOSize {
+		return nil, fmt.Errorf("invalid ICO bitmap size")
+	}
+	if headerSize < 40 || uint64(headerSize) > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid ICO bitmap header")
+	}
+
+	// The size limit keeps stride*height far from overflowing
+	stride := ((width*bitCount + 31) / 32) * 4
+	maskStride := ((width + 31) / 32) * 4
+	pixels := data[headerSize:]
+	if len(pixels) < stride*height {
+		return nil, fmt.Errorf("truncated ICO bitmap")
+	}
+	mask := pixels[stride*height:]
+	hasMask := len(mask) >= maskStride*height
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up
+		row := pixels[(height-1-y)*stride:]
+		maskRow := []byte(nil)
+		if hasMask {
+			maskRow = mask[(height-1-y)*maskStride:]
+		}
+
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			if bitCount == 32 {
+				p := row[x*4:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]}
+			} else {
+				p := row[x*3:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff}
+				if maskRow != nil && maskRow[x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img, nil
+}
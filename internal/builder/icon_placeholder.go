@@ -0,0 +1,228 @@
+package builder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+	"github.com/hajimehoshi/bitmapfont/v3"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// placeholderSize is the size placeholder icons are rendered at before resizing
+const placeholderSize = 1024
+
+// placeholderRadius is the corner radius of placeholder icons, in percent of the size
+const placeholderRadius = 22
+
+// placeholderText returns the text drawn on a placeholder icon
+// Latin names use up to two initials ("my-cool app" -> "MC"),
+// other scripts (CJK, ...) use their first glyph
+func placeholderText(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+
+	first, _ := utf8.DecodeRuneInString(name)
+	if first > unicode.MaxASCII {
+		return string(first)
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var initials []rune
+	for _, word := range words {
+		r, _ := utf8.DecodeRuneInString(word)
+		if r > unicode.MaxASCII {
+			break
+		}
+		initials = append(initials, unicode.ToUpper(r))
+		if len(initials) == 2 {
+			break
+		}
+	}
+
+	return string(initials)
+}
+
+// placeholderColor derives a stable background color from the app name
+// The hash picks the hue, saturation and lightness are fixed so white text stays readable
+func placeholderColor(appname string) color.NRGBA {
+	hash := fnv.New32a()
+	hash.Write([]byte(appname))
+	hue := float64(hash.Sum32() % 360)
+
+	return hslToRGB(hue, 0.55, 0.45)
+}
+
+// hslToRGB converts a hue (degrees), saturation and lightness (0-1) to an opaque color
+func hslToRGB(h, s, l float64) color.NRGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.NRGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
+
+// placeholderFace returns a font face able to draw every rune of text
+// Go Bold (vector) is used when it covers the text, the embedded CJK bitmap font
+// otherwise; bitmap reports whether the returned face is the bitmap font
+func placeholderFace(text string) (face font.Face, bitmap bool, err error) {
+	f, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse embedded font: %w", err)
+	}
+
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if index, err := f.GlyphIndex(&buf, r); err != nil || index == 0 {
+			return bitmapfont.FaceSC, true, nil
+		}
+	}
+
+	face, err = opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    placeholderSize / 2,
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+	return face, false, err
+}
+
+// renderTextMask draws text with the face into an alpha mask cropped to the ink bounds
+func renderTextMask(text string, face font.Face) image.Image {
+	bounds, _ := font.BoundString(face, text)
+	rect := image.Rect(
+		bounds.Min.X.Floor(), bounds.Min.Y.Floor(),
+		bounds.Max.X.Ceil(), bounds.Max.Y.Ceil(),
+	)
+
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	drawer := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(-rect.Min.X, -rect.Min.Y),
+	}
+	drawer.DrawString(text)
+
+	// Bitmap faces report the whole glyph cell, trim it down to the ink
+	return trimTransparent(mask)
+}
+
+// RenderPlaceholderIcon renders an icon from the display name on a color derived from appname
+func RenderPlaceholderIcon(displayName, appname string) (image.Image, error) {
+	text := placeholderText(displayName)
+	if text == "" {
+		text = placeholderText(appname)
+	}
+	if text == "" {
+		return nil, fmt.Errorf("no text to render for app %q", appname)
+	}
+
+	face, bitmap, err := placeholderFace(text)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	dst := image.NewNRGBA(image.Rect(0, 0, placeholderSize, placeholderSize))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{placeholderColor(appname)}, image.Point{}, draw.Src)
+
+	mask := renderTextMask(text, face)
+	if mask.Bounds().Empty() {
+		return nil, fmt.Errorf("no glyphs to render for %q", text)
+	}
+
+	// Fit the text into the central 50% of the icon, keeping its aspect ratio
+	maskWidth, maskHeight := float64(mask.Bounds().Dx()), float64(mask.Bounds().Dy())
+	box := float64(placeholderSize) / 2
+	scale := math.Min(box/maskWidth, box/maskHeight)
+
+	// Bitmap glyphs are upscaled by a whole factor with crisp pixel edges
+	filter := imaging.Lanczos
+	if bitmap {
+		scale = math.Floor(scale)
+		filter = imaging.NearestNeighbor
+	}
+
+	width := int(math.Round(maskWidth * scale))
+	height := int(math.Round(maskHeight * scale))
+	scaled := imaging.Resize(mask, width, height, filter)
+
+	offset := image.Pt((placeholderSize-width)/2, (placeholderSize-height)/2)
+	draw.DrawMask(dst, scaled.Bounds().Add(offset), image.White, image.Point{}, alphaOf(scaled), image.Point{}, draw.Over)
+
+	roundCorners(dst, placeholderSize*placeholderRadius/100)
+
+	return dst, nil
+}
+
+// alphaOf extracts the alpha channel of an image as a mask
+func alphaOf(img *image.NRGBA) *image.Alpha {
+	mask := image.NewAlpha(img.Bounds())
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			mask.SetAlpha(x, y, color.Alpha{A: img.NRGBAAt(x, y).A})
+		}
+	}
+	return mask
+}
+
+// copyPlaceholderIcons renders a placeholder from the app's display name and writes all sizes
+func (h *IconHandler) copyPlaceholderIcons() error {
+	var manifest map[string]interface{}
+	var i18n parser.I18nConfig
+	if h.builder.Compose != nil {
+		manifest = h.builder.Compose.XFnpack.Manifest
+		i18n = h.builder.Compose.XFnpack.I18n
+	}
+	displayName := parser.GetLocalizedManifestValue(manifest, "display_name", i18n.Default(), i18n.Default(), h.builder.AppName)
+
+	img, err := RenderPlaceholderIcon(displayName, h.builder.AppName)
+	if err != nil {
+		return err
+	}
+
+	if h.builder.Verbose {
+		fmt.Printf("Rendered placeholder icon for %q\n", displayName)
+	}
+
+	return h.CopyIcons(img)
+}
@@ -0,0 +1,134 @@
+package builder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlaceholderText(t *testing.T) {
+	tests := map[string]string{
+		"":                    "",
+		"  ":                  "",
+		"grafana":             "G",
+		"my-cool app":         "MC",
+		"Home Assistant Core": "HA",
+		"2fauth":              "2",
+		"网页应用":                "网",
+		"app 网页":              "A",
+	}
+	for name, want := range tests {
+		if got := placeholderText(name); got != want {
+			t.Errorf("placeholderText(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPlaceholderColor(t *testing.T) {
+	if placeholderColor("grafana") != placeholderColor("grafana") {
+		t.Error("the color must be stable for an app name")
+	}
+	if placeholderColor("grafana") == placeholderColor("prometheus") {
+		t.Error("different app names should get different colors")
+	}
+	if c := placeholderColor("grafana"); c.A != 0xff {
+		t.Errorf("color %v must be opaque", c)
+	}
+
+	// Primary hues at the fixed saturation and lightness
+	for hue, want := range map[float64]color.NRGBA{
+		0:   {R: 0xb2, G: 0x34, B: 0x34, A: 0xff},
+		120: {R: 0x34, G: 0xb2, B: 0x34, A: 0xff},
+		240: {R: 0x34, G: 0x34, B: 0xb2, A: 0xff},
+	} {
+		if got := hslToRGB(hue, 0.55, 0.45); got != want {
+			t.Errorf("hslToRGB(%v) = %v, want %v", hue, got, want)
+		}
+	}
+}
+
+func TestRenderPlaceholderIcon(t *testing.T) {
+	first, err := RenderPlaceholderIcon("My App", "my-app")
+	if err != nil {
+		t.Fatalf("RenderPlaceholderIcon failed: %v", err)
+	}
+	second, err := RenderPlaceholderIcon("My App", "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same app name renders the same pixels
+	a, b := first.(*image.NRGBA), second.(*image.NRGBA)
+	if !bytes.Equal(a.Pix, b.Pix) {
+		t.Error("the placeholder must be deterministic for an app name")
+	}
+	if bounds := a.Bounds(); bounds.Dx() != placeholderSize || bounds.Dy() != placeholderSize {
+		t.Errorf("size = %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), placeholderSize, placeholderSize)
+	}
+
+	// Background color at the edge, white text in the middle, rounded corners
+	if got := a.NRGBAAt(placeholderSize/2, 10); got != placeholderColor("my-app") {
+		t.Errorf("background = %v, want %v", got, placeholderColor("my-app"))
+	}
+	if a.NRGBAAt(0, 0).A != 0 {
+		t.Error("corners must be transparent")
+	}
+	white := 0
+	for x := placeholderSize / 4; x < placeholderSize*3/4; x++ {
+		if c := a.NRGBAAt(x, placeholderSize/2); c.R == 0xff && c.G == 0xff && c.B == 0xff {
+			white++
+		}
+	}
+	if white == 0 {
+		t.Error("expected white text across the middle")
+	}
+
+	other, err := RenderPlaceholderIcon("My App", "other-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a.Pix, other.(*image.NRGBA).Pix) {
+		t.Error("a different app name must change the placeholder")
+	}
+}
+
+func TestRenderPlaceholderIconFallbacks(t *testing.T) {
+	// CJK names use the bitmap font
+	if _, err := RenderPlaceholderIcon("网页应用", "web"); err != nil {
+		t.Errorf("RenderPlaceholderIcon failed for a CJK name: %v", err)
+	}
+	// Without a display name the app name is used
+	if _, err := RenderPlaceholderIcon("", "web"); err != nil {
+		t.Errorf("RenderPlaceholderIcon failed without display name: %v", err)
+	}
+	if _, err := RenderPlaceholderIcon("", ""); err == nil {
+		t.Error("expected an error without any name")
+	}
+}
+
+func TestBuildPlaceholderIcon(t *testing.T) {
+	// Without an icon file the build writes a placeholder
+	b := newTestApp(t, map[string]string{"compose.yaml": testCompose})
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	first, err := os.ReadFile(filepath.Join(b.GetAppDir(), "ICON_256.PNG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b = newTestApp(t, map[string]string{"compose.yaml": testCompose})
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	second, err := os.ReadFile(filepath.Join(b.GetAppDir(), "ICON_256.PNG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("the placeholder icon must be identical across builds")
+	}
+}
@@ -0,0 +1,207 @@
+package builder
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// trimAlphaThreshold is the alpha value at or below which a pixel counts as transparent
+const trimAlphaThreshold = 8
+
+// validateIconStyle checks the ranges of the icon style options
+func validateIconStyle(style parser.IconStyle) error {
+	if style.Padding < 0 || style.Padding >= 50 {
+		return fmt.Errorf("icon_style.padding must be between 0 and 50 (percent), got %v", style.Padding)
+	}
+	if style.Radius < 0 || style.Radius > 50 {
+		return fmt.Errorf("icon_style.radius must be between 0 and 50 (percent), got %v", style.Radius)
+	}
+	if style.Sharpen < 0 {
+		return fmt.Errorf("icon_style.sharpen must not be negative, got %v", style.Sharpen)
+	}
+	if _, _, err := parseBackground(style.Background); err != nil {
+		return fmt.Errorf("icon_style.background: %w", err)
+	}
+	return nil
+}
+
+// trimTransparent crops fully transparent borders from an image
+// Returns the image unchanged if it has no opaque pixel
+func trimTransparent(src image.Image) image.Image {
+	bounds := src.Bounds()
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X-1, bounds.Min.Y-1
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := src.At(x, y).RGBA()
+			if a>>8 <= trimAlphaThreshold {
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+
+	if maxX < minX || maxY < minY {
+		return src
+	}
+	return imaging.Crop(src, image.Rect(minX, minY, maxX+1, maxY+1))
+}
+
+// renderIcon produces a styled square icon of the given size from a squared source
+// Order: downscale into the padded area, sharpen, draw over the background, round corners
+func (h *IconHandler) renderIcon(src image.Image, size int) image.Image {
+	style := h.style()
+
+	contentSize := size - 2*int(math.Round(float64(size)*style.Padding/100))
+	if contentSize < 1 {
+		contentSize = 1
+	}
+
+	content := h.ResizeIcon(src, contentSize, contentSize)
+	if style.Sharpen > 0 {
+		content = imaging.Sharpen(content, style.Sharpen)
+	}
+
+	if contentSize == size && style.Background == "" && style.Radius == 0 {
+		return content
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	fillBackground(dst, style.Background)
+
+	offset := (size - contentSize) / 2
+	draw.Draw(dst, image.Rect(offset, offset, offset+contentSize, offset+contentSize), content, image.Point{}, draw.Over)
+
+	if style.Radius > 0 {
+		roundCorners(dst, float64(size)*style.Radius/100)
+	}
+
+	return dst
+}
+
+// style returns the icon style of the compose file (zero value if not parsed)
+func (h *IconHandler) style() parser.IconStyle {
+	if h.builder.Compose == nil {
+		return parser.IconStyle{}
+	}
+	return h.builder.Compose.XFnpack.IconStyle
+}
+
+// parseBackground parses a background spec into its top and bottom colors
+// A solid color returns the same color twice; an empty spec is transparent
+func parseBackground(spec string) (top, bottom color.NRGBA, err error) {
+	if strings.TrimSpace(spec) == "" {
+		return color.NRGBA{}, color.NRGBA{}, nil
+	}
+
+	parts := strings.Split(spec, ",")
+	if len(parts) > 2 {
+		return top, bottom, fmt.Errorf("expected one color or two comma-separated colors, got %q", spec)
+	}
+
+	if top, err = parseHexColor(parts[0]); err != nil {
+		return top, bottom, err
+	}
+	bottom = top
+	if len(parts) == 2 {
+		if bottom, err = parseHexColor(parts[1]); err != nil {
+			return top, bottom, err
+		}
+	}
+	return top, bottom, nil
+}
+
+// parseHexColor parses #rgb, #rrggbb or #rrggbbaa colors
+func parseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	return color.NRGBA{
+		R: uint8(value >> 24),
+		G: uint8(value >> 16),
+		B: uint8(value >> 8),
+		A: uint8(value),
+	}, nil
+}
+
+// fillBackground fills the image with a solid color or a vertical gradient
+func fillBackground(dst *image.NRGBA, spec string) {
+	top, bottom, err := parseBackground(spec)
+	if err != nil || spec == "" {
+		return
+	}
+
+	bounds := dst.Bounds()
+	height := bounds.Dy()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		t := 0.0
+		if height > 1 {
+			t = float64(y-bounds.Min.Y) / float64(height-1)
+		}
+		c := color.NRGBA{
+			R: lerp(top.R, bottom.R, t),
+			G: lerp(top.G, bottom.G, t),
+			B: lerp(top.B, bottom.B, t),
+			A: lerp(top.A, bottom.A, t),
+		}
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+// lerp interpolates linearly between two color channel values
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+}
+
+// roundCorners masks the corners of the image with an anti-aliased radius
+func roundCorners(img *image.NRGBA, radius float64) {
+	bounds := img.Bounds()
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Pixel center relative to the image origin
+			px := float64(x-bounds.Min.X) + 0.5
+			py := float64(y-bounds.Min.Y) + 0.5
+
+			// Nearest corner circle center; pixels between the centers are inside
+			cx := math.Max(radius, math.Min(px, width-radius))
+			cy := math.Max(radius, math.Min(py, height-radius))
+			dist := math.Hypot(px-cx, py-cy)
+
+			coverage := math.Max(0, math.Min(1, radius-dist+0.5))
+			if coverage >= 1 {
+				continue
+			}
+
+			c := img.NRGBAAt(x, y)
+			c.A = uint8(math.Round(float64(c.A) * coverage))
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
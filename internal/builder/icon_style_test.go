@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// solidImage creates an image of the given size filled with a single color
+func solidImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// styledHandler creates an icon handler with the given icon style
+func styledHandler(style parser.IconStyle) *IconHandler {
+	b := NewBuilder("", "", false)
+	b.Compose = &parser.ComposeFile{}
+	b.Compose.XFnpack.IconStyle = style
+	return NewIconHandler(b)
+}
+
+// alphaAt returns the alpha value of a pixel
+func alphaAt(img image.Image, x, y int) uint8 {
+	return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA).A
+}
+
+func TestValidateIconStyle(t *testing.T) {
+	tests := []struct {
+		name  string
+		style parser.IconStyle
+		err   string
+	}{
+		{"empty", parser.IconStyle{}, ""},
+		{"all options", parser.IconStyle{Trim: true, Padding: 10, Radius: 50, Sharpen: 0.5, Background: "#fff,#000000"}, ""},
+		{"negative padding", parser.IconStyle{Padding: -1}, "padding"},
+		{"padding too large", parser.IconStyle{Padding: 50}, "padding"},
+		{"radius too large", parser.IconStyle{Radius: 51}, "radius"},
+		{"negative sharpen", parser.IconStyle{Sharpen: -0.5}, "sharpen"},
+		{"invalid color", parser.IconStyle{Background: "blue"}, "background"},
+		{"three colors", parser.IconStyle{Background: "#fff,#eee,#ddd"}, "background"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIconStyle(tt.style)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("validateIconStyle failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("validateIconStyle error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := map[string]color.NRGBA{
+		"#fff":      {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		"#1e88e5":   {R: 0x1e, G: 0x88, B: 0xe5, A: 0xff},
+		" 1e88e580": {R: 0x1e, G: 0x88, B: 0xe5, A: 0x80},
+	}
+	for spec, want := range tests {
+		if got, err := parseHexColor(spec); err != nil || got != want {
+			t.Errorf("parseHexColor(%q) = %v, %v, want %v", spec, got, err, want)
+		}
+	}
+
+	for _, spec := range []string{"", "#ff", "#gggggg", "#1e88e5f"} {
+		if _, err := parseHexColor(spec); err == nil {
+			t.Errorf("parseHexColor(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestTrimTransparent(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 5; y < 7; y++ {
+		for x := 3; x < 7; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 0xff, A: 0xff})
+		}
+	}
+	// Nearly transparent pixels count as transparent
+	img.SetNRGBA(0, 0, color.NRGBA{A: trimAlphaThreshold})
+
+	trimmed := trimTransparent(img)
+	if b := trimmed.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Errorf("trimmed size = %dx%d, want 4x2", b.Dx(), b.Dy())
+	}
+
+	empty := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	if trimTransparent(empty) != image.Image(empty) {
+		t.Error("a fully transparent image must be returned unchanged")
+	}
+}
+
+func TestRenderIconPadding(t *testing.T) {
+	src := solidImage(128, 128, color.NRGBA{R: 0xff, A: 0xff})
+
+	img := styledHandler(parser.IconStyle{Padding: 25}).renderIcon(src, 64)
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Fatalf("size = %dx%d, want 64x64", b.Dx(), b.Dy())
+	}
+	// 25% padding leaves a 32px content square in the middle
+	for _, p := range []image.Point{{0, 0}, {15, 32}, {48, 32}, {32, 63}} {
+		if a := alphaAt(img, p.X, p.Y); a != 0 {
+			t.Errorf("padding pixel %v has alpha %d, want 0", p, a)
+		}
+	}
+	for _, p := range []image.Point{{16, 16}, {32, 32}, {47, 47}} {
+		if a := alphaAt(img, p.X, p.Y); a != 0xff {
+			t.Errorf("content pixel %v has alpha %d, want 255", p, a)
+		}
+	}
+}
+
+func TestRenderIconBackground(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	img := styledHandler(parser.IconStyle{Background: "#ff0000,#0000ff"}).renderIcon(src, 64)
+
+	top := color.NRGBAModel.Convert(img.At(10, 0)).(color.NRGBA)
+	bottom := color.NRGBAModel.Convert(img.At(10, 63)).(color.NRGBA)
+	middle := color.NRGBAModel.Convert(img.At(10, 32)).(color.NRGBA)
+	if top != (color.NRGBA{R: 0xff, A: 0xff}) || bottom != (color.NRGBA{B: 0xff, A: 0xff}) {
+		t.Errorf("gradient runs from %v to %v, want red to blue", top, bottom)
+	}
+	if middle.R == 0 || middle.B == 0 {
+		t.Errorf("middle of the gradient = %v, want a blend", middle)
+	}
+}
+
+func TestRenderIconCorners(t *testing.T) {
+	src := solidImage(64, 64, color.NRGBA{G: 0xff, A: 0xff})
+	img := styledHandler(parser.IconStyle{Radius: 25}).renderIcon(src, 64)
+
+	for _, p := range []image.Point{{0, 0}, {63, 0}, {0, 63}, {63, 63}} {
+		if a := alphaAt(img, p.X, p.Y); a != 0 {
+			t.Errorf("corner pixel %v has alpha %d, want 0", p, a)
+		}
+	}
+	for _, p := range []image.Point{{32, 0}, {0, 32}, {32, 32}} {
+		if a := alphaAt(img, p.X, p.Y); a != 0xff {
+			t.Errorf("edge pixel %v has alpha %d, want 255", p, a)
+		}
+	}
+	// The curve is anti-aliased
+	partial := false
+	for x := 0; x < 16; x++ {
+		if a := alphaAt(img, x, 2); a > 0 && a < 0xff {
+			partial = true
+		}
+	}
+	if !partial {
+		t.Error("expected partially transparent pixels along the curve")
+	}
+}
+
+func TestRenderIconSharpen(t *testing.T) {
+	// A hard edge gains contrast next to it when sharpened
+	src := image.NewNRGBA(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			c := color.NRGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xff}
+			if x >= 64 {
+				c = color.NRGBA{R: 0xc0, G: 0xc0, B: 0xc0, A: 0xff}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+
+	plain := styledHandler(parser.IconStyle{}).renderIcon(src, 64)
+	sharp := styledHandler(parser.IconStyle{Sharpen: 1}).renderIcon(src, 64)
+	if b := sharp.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Fatalf("size = %dx%d, want 64x64", b.Dx(), b.Dy())
+	}
+
+	plainDark := color.NRGBAModel.Convert(plain.At(30, 32)).(color.NRGBA).R
+	sharpDark := color.NRGBAModel.Convert(sharp.At(30, 32)).(color.NRGBA).R
+	plainLight := color.NRGBAModel.Convert(plain.At(33, 32)).(color.NRGBA).R
+	sharpLight := color.NRGBAModel.Convert(sharp.At(33, 32)).(color.NRGBA).R
+	if sharpDark >= plainDark || sharpLight <= plainLight {
+		t.Errorf("sharpening did not increase the edge contrast: dark %d -> %d, light %d -> %d",
+			plainDark, sharpDark, plainLight, sharpLight)
+	}
+}
@@ -0,0 +1,222 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// testSVG is a 200x100 SVG icon
+const testSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 200 100">
+<rect width="200" height="100" fill="#ff0000"/>
+</svg>`
+
+// testWebP is a 1x1 lossless WebP image
+var testWebP = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+// encodePNG encodes a solid image of the given size
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildICO creates an ICO file with a single image entry
+func buildICO(width uint8, image []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []uint16{0, 1, 1})
+	binary.Write(&buf, binary.LittleEndian, icoEntry{
+		Width:    width,
+		Height:   width,
+		Planes:   1,
+		BitCount: 32,
+		Size:     uint32(len(image)),
+		Offset:   6 + 16,
+	})
+	buf.Write(image)
+	return buf.Bytes()
+}
+
+// buildDIB creates a 32-bit ICO bitmap of the given size with a single color
+func buildDIB(width, height int, c color.NRGBA) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, struct {
+		HeaderSize    uint32
+		Width, Height int32
+		Planes        uint16
+		BitCount      uint16
+		Rest          [24]byte
+	}{HeaderSize: 40, Width: int32(width), Height: int32(height * 2), Planes: 1, BitCount: 32})
+	for i := 0; i < width*height; i++ {
+		buf.Write([]byte{c.B, c.G, c.R, c.A})
+	}
+	return buf.Bytes()
+}
+
+func TestFindIconPriority(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		icon  string
+		want  string
+	}{
+		{"icon before logo", []string{"logo.png", "icon.svg"}, "", "icon.svg"},
+		{"png before svg", []string{"icon.svg", "icon.png"}, "", "icon.png"},
+		{"logo before other png", []string{"a.png", "logo.webp"}, "", "logo.webp"},
+		{"case insensitive", []string{"ICON.ICO"}, "", "ICON.ICO"},
+		{"fallback png", []string{"b.png", "a.png", "readme.md"}, "", "a.png"},
+		{"configured icon", []string{"icon.png", "assets/app.svg"}, "assets/app.svg", "assets/app.svg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			for _, name := range tt.files {
+				files[name] = ""
+			}
+			b := newTestApp(t, files)
+			b.Compose = &parser.ComposeFile{}
+			b.Compose.XFnpack.Icon = tt.icon
+
+			got, err := NewIconHandler(b).FindIcon()
+			if err != nil {
+				t.Fatalf("FindIcon failed: %v", err)
+			}
+			if want := filepath.Join(b.InputDir, tt.want); got != want {
+				t.Errorf("FindIcon = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestFindIconErrors(t *testing.T) {
+	b := newTestApp(t, map[string]string{"icon.gif": ""})
+	if _, err := NewIconHandler(b).FindIcon(); err == nil {
+		t.Error("expected an error without a supported icon")
+	}
+
+	b.Compose = &parser.ComposeFile{}
+	b.Compose.XFnpack.Icon = "icon.gif"
+	if _, err := NewIconHandler(b).FindIcon(); err == nil {
+		t.Error("expected an error for an unsupported configured icon")
+	}
+	b.Compose.XFnpack.Icon = "missing.png"
+	if _, err := NewIconHandler(b).FindIcon(); err == nil {
+		t.Error("expected an error for a missing configured icon")
+	}
+}
+
+func TestLoadIcon(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	tests := []struct {
+		name          string
+		content       []byte
+		width, height int
+	}{
+		{"icon.svg", []byte(testSVG), svgRasterSize, svgRasterSize / 2},
+		{"icon.webp", testWebP, 1, 1},
+		{"png.ico", buildICO(48, encodePNG(t, 48, 48)), 48, 48},
+		{"bitmap.ico", buildICO(16, buildDIB(16, 8, red)), 16, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iconPath := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(iconPath, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			img, err := loadIcon(iconPath)
+			if err != nil {
+				t.Fatalf("loadIcon failed: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+				t.Errorf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.width, tt.height)
+			}
+		})
+	}
+
+	// The bitmap pixels are stored as BGRA
+	img, err := decodeICOBitmap(buildDIB(2, 2, red))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(1, 1)); got != red {
+		t.Errorf("pixel = %v, want %v", got, red)
+	}
+}
+
+func TestLoadICOInvalid(t *testing.T) {
+	valid := buildDIB(16, 16, color.NRGBA{A: 0xff})
+
+	hugeHeader := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(hugeHeader[0:4], 1<<31)
+	hugeSize := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(hugeSize[4:8], 1<<30)
+	binary.LittleEndian.PutUint32(hugeSize[8:12], 1<<30)
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"empty", nil},
+		{"garbage", []byte("definitely not an icon file")},
+		{"no entries", []byte{0, 0, 1, 0, 0, 0}},
+		{"truncated directory", []byte{0, 0, 1, 0, 2, 0, 16, 16}},
+		{"offset out of range", buildICO(16, valid)[:40]},
+		{"truncated bitmap", buildICO(16, valid[:len(valid)-100])},
+		{"short bitmap header", buildICO(16, valid[:20])},
+		{"header size beyond data", buildICO(16, hugeHeader)},
+		{"huge dimensions", buildICO(16, hugeSize)},
+		{"truncated png", buildICO(48, encodePNG(t, 48, 48)[:30])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iconPath := filepath.Join(t.TempDir(), "icon.ico")
+			if err := os.WriteFile(iconPath, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadIcon(iconPath); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestProcessUIEntryIconsOutsideInput(t *testing.T) {
+	for _, icon := range []string{"../outside.png", "linked.png"} {
+		t.Run(icon, func(t *testing.T) {
+			b := newTestApp(t, map[string]string{"compose.yaml": testCompose})
+			outside := filepath.Join(filepath.Dir(b.InputDir), "outside.png")
+			if err := os.WriteFile(outside, encodePNG(t, 64, 64), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(outside, filepath.Join(b.InputDir, "linked.png")); err != nil {
+				t.Fatal(err)
+			}
+			b.UIEntries = []parser.UIEntry{{Name: "web", Icon: icon}}
+
+			err := NewIconHandler(b).ProcessUIEntryIcons()
+			if err == nil || !strings.Contains(err.Error(), "is outside the input directory") {
+				t.Errorf("ProcessUIEntryIcons error = %v, want an outside input error", err)
+			}
+		})
+	}
+}
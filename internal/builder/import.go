@@ -0,0 +1,196 @@
+package builder
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// iconDownloadTimeout bounds the download of the icon of an imported app
+const iconDownloadTimeout = 30 * time.Second
+
+// iconMediaTypes maps the icon content types to the icon file extensions
+var iconMediaTypes = map[string]string{
+	"image/png":                ".png",
+	"image/svg+xml":            ".svg",
+	"image/webp":               ".webp",
+	"image/jpeg":               ".jpg",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// ImportOptions configures the conversion of an app of another app store
+type ImportOptions struct {
+	// Format is the source format (see parser.ImportFormats)
+	Format string
+
+	// Input is the CasaOS compose file or directory, the Umbrel app directory
+	// or the Portainer templates JSON file
+	Input string
+
+	// ComposeFile is the compose file of a Portainer stack template
+	ComposeFile string
+
+	// Template selects the Portainer template by title or name
+	Template string
+
+	// OutputDir receives compose.yaml and icon.png (default: ./<appname>)
+	OutputDir string
+
+	// Force overwrites an existing compose.yaml
+	Force bool
+
+	Verbose bool
+}
+
+// ImportApp converts an app of another app store and writes the compose file
+// and the downloaded icon to the output directory
+// Returns the conversion result and the output directory, a failed icon
+// download is listed as unmapped
+func ImportApp(opts ImportOptions) (*parser.ImportResult, string, error) {
+	src, err := readImportSource(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err := parser.Import(opts.Format, src)
+	if err != nil {
+		return nil, "", &SourceError{File: opts.Input, Err: err}
+	}
+
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = parser.GetManifestValue(result.Compose.XFnpack.Manifest, "appname", "")
+		if outputDir == "" {
+			return nil, "", fmt.Errorf("the app has no name, set the output directory")
+		}
+	}
+	composePath := filepath.Join(outputDir, "compose.yaml")
+	if _, err := os.Stat(composePath); err == nil && !opts.Force {
+		return nil, "", fmt.Errorf("%s already exists (use --force to overwrite)", composePath)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(composePath, result.Content, 0644); err != nil {
+		return nil, "", fmt.Errorf("failed to write compose file: %w", err)
+	}
+	if opts.Verbose {
+		fmt.Printf("Written: %s\n", composePath)
+	}
+
+	if result.IconURL != "" {
+		iconPath := filepath.Join(outputDir, "icon.png")
+		if err := downloadIcon(result.IconURL, iconPath); err != nil {
+			result.Unmapped = append(result.Unmapped, fmt.Sprintf("icon: %v", err))
+		} else if opts.Verbose {
+			fmt.Printf("Written: %s\n", iconPath)
+		}
+	}
+
+	return result, outputDir, nil
+}
+
+// readImportSource reads the files of the source app
+func readImportSource(opts ImportOptions) (parser.ImportSource, error) {
+	src := parser.ImportSource{Template: opts.Template}
+
+	var composePath, metadataPath string
+	switch opts.Format {
+	case "casaos":
+		composePath = opts.Input
+		if info, err := os.Stat(opts.Input); err == nil && info.IsDir() {
+			found, err := FindComposeFile(opts.Input)
+			if err != nil {
+				return src, err
+			}
+			composePath = found
+		}
+	case "umbrel":
+		metadataPath = filepath.Join(opts.Input, "umbrel-app.yml")
+		found, err := FindComposeFile(opts.Input)
+		if err != nil {
+			return src, err
+		}
+		composePath = found
+	case "portainer":
+		metadataPath = opts.Input
+		composePath = opts.ComposeFile
+	default:
+		return src, fmt.Errorf("unknown import format %q (supported: %v)", opts.Format, parser.ImportFormats)
+	}
+
+	if composePath != "" {
+		data, err := os.ReadFile(composePath)
+		if err != nil {
+			return src, fmt.Errorf("failed to read compose file: %w", err)
+		}
+		src.Compose = data
+	}
+	if metadataPath != "" {
+		data, err := os.ReadFile(metadataPath)
+		if err != nil {
+			return src, fmt.Errorf("failed to read app metadata: %w", err)
+		}
+		src.Metadata = data
+	}
+	return src, nil
+}
+
+// downloadIcon downloads an icon and saves it as PNG
+// SVG, WebP, JPEG and ICO icons are converted
+func downloadIcon(url, destPath string) error {
+	client := &http.Client{Timeout: iconDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	// The extension selects the decoder, the URL takes precedence over the content type
+	ext := path.Ext(resp.Request.URL.Path)
+	if !isIconFile("icon" + ext) {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		ext = iconMediaTypes[mediaType]
+		if ext == "" {
+			ext = ".png"
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "fpk-icon-*"+ext)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	tmp.Close()
+
+	img, err := loadIcon(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+
+	outFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer outFile.Close()
+	if err := png.Encode(outFile, img); err != nil {
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// composeDir is the package directory holding the compose file,
+// relative bind mounts resolve against it
+const composeDir = "app/docker"
+
+// CopyIncludes copies the input files matched by x-fnpack.include into the app directory
+// File permissions are preserved
+func (b *Builder) CopyIncludes() error {
+	inputFS := os.DirFS(b.InputDir)
+
+	for _, rule := range b.Compose.XFnpack.Include {
+		if !doublestar.ValidatePattern(rule.From) || path.IsAbs(rule.From) || strings.HasPrefix(path.Clean(rule.From), "..") {
+			return fmt.Errorf("include pattern %q is invalid", rule.From)
+		}
+
+		dest := rule.Destination()
+		if err := validatePackagePath(dest); err != nil {
+			return fmt.Errorf("include destination %q: %w", dest, err)
+		}
+
+		matches, err := doublestar.Glob(inputFS, rule.From, doublestar.WithFilesOnly())
+		if err != nil {
+			return fmt.Errorf("failed to match include pattern %q: %w", rule.From, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("include pattern %q matches no files", rule.From)
+		}
+		sort.Strings(matches)
+
+		if !strings.HasSuffix(dest, "/") {
+			if len(matches) != 1 {
+				return fmt.Errorf("include pattern %q matches %d files, destination %q must be a directory (end with /)", rule.From, len(matches), dest)
+			}
+			if err := b.copyInclude(matches[0], dest); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Without an explicit destination the full path is kept,
+		// otherwise the path relative to the static prefix of the pattern
+		base := ""
+		if rule.To != "" {
+			base = staticPrefix(rule.From)
+		}
+		for _, match := range matches {
+			rel := strings.TrimPrefix(strings.TrimPrefix(match, base), "/")
+			if err := b.copyInclude(match, path.Join(dest, rel)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyInclude copies a file of the input directory to a package path
+func (b *Builder) copyInclude(src, dest string) error {
+	srcPath, err := b.includeSource(src)
+	if err != nil {
+		return err
+	}
+	destPath := filepath.Join(b.GetAppDir(), filepath.FromSlash(dest))
+
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read included file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dest, err)
+	}
+
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read included file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to write included file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write included file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write included file: %w", err)
+	}
+	// The umask may have dropped permission bits on creation
+	if err := os.Chmod(destPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", dest, err)
+	}
+
+	if b.Verbose {
+		fmt.Printf("Written: %s\n", destPath)
+	}
+	return nil
+}
+
+// errOutsideInput reports a file that resolves outside the input directory
+var errOutsideInput = errors.New("outside the input directory")
+
+// includeSource resolves the symlinks of an included file of the input directory
+// A file that resolves outside the input directory is refused
+func (b *Builder) includeSource(src string) (string, error) {
+	resolved, err := b.resolveInputFile(src)
+	if errors.Is(err, errOutsideInput) {
+		return "", fmt.Errorf("included file %s links outside the input directory", src)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read included file: %w", err)
+	}
+	return resolved, nil
+}
+
+// resolveInputFile resolves a path relative to the input directory and its symlinks
+// Returns errOutsideInput if the file is not inside the input directory
+func (b *Builder) resolveInputFile(name string) (string, error) {
+	root, err := filepath.EvalSymlinks(b.InputDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve input directory: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errOutsideInput
+	}
+	return resolved, nil
+}
+
+// staticPrefix returns the directories of a glob pattern before the first wildcard
+// A pattern without wildcards returns its parent directory
+func staticPrefix(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, `*?[{\`) {
+			return strings.Join(parts[:i], "/")
+		}
+	}
+	if dir := path.Dir(pattern); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// validatePackagePath checks that a destination stays inside the package
+func validatePackagePath(p string) error {
+	if path.IsAbs(p) {
+		return fmt.Errorf("must be relative to the package root")
+	}
+	clean := path.Clean(p)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("must be inside the package")
+	}
+	return nil
+}
+
+// ValidateBindMounts checks that every bind mount with a relative source
+// resolves to a file or directory of the generated package
+// Relative sources resolve against app/docker, next to the compose file
+func (b *Builder) ValidateBindMounts() error {
+	names := make([]string, 0, len(b.Compose.Services))
+	for name := range b.Compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		for _, volume := range b.Compose.Services[name].Volumes {
+			source, _, ok := strings.Cut(volume, ":")
+			if !ok || !isRelativeSource(source) {
+				continue
+			}
+
+			target := path.Join(composeDir, source)
+			if err := validatePackagePath(target); err != nil {
+				problems = append(problems, fmt.Sprintf("service %s: bind mount source %s points outside the package", name, source))
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(b.GetAppDir(), filepath.FromSlash(target))); err != nil {
+				problems = append(problems, fmt.Sprintf("service %s: bind mount source %s is not in the package (add it to x-fnpack.include)", name, source))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isRelativeSource reports whether a volume source is a relative host path
+// Named volumes and absolute paths are left alone
+func isRelativeSource(source string) bool {
+	return source == "." || source == ".." || strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
@@ -0,0 +1,171 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// includeCompose is an app with include rules and a bind mount of an included directory
+const includeCompose = `x-fnpack:
+  manifest:
+    appname: demo
+    version: "1.0.0"
+  include:
+    - conf/** -> app/docker/conf/
+    - sql/*.sql
+    - env.tpl -> app/docker/.env.example
+    - from: screenshots/*.png
+      to: app/ui/images/
+services:
+  web:
+    image: nginx:1.25
+    volumes:
+      - ./conf/nginx:/etc/nginx/conf.d
+`
+
+func TestCopyIncludes(t *testing.T) {
+	b := newTestApp(t, map[string]string{
+		"compose.yaml":          includeCompose,
+		"conf/nginx/site.conf":  "server {}",
+		"conf/mime.types":       "types {}",
+		"sql/init.sql":          "CREATE TABLE t;",
+		"env.tpl":               "KEY=value",
+		"screenshots/home.png":  "png",
+		"screenshots/notes.txt": "not included",
+	})
+	if err := os.Chmod(filepath.Join(b.InputDir, "sql", "init.sql"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	appDir := b.GetAppDir()
+	for dest, content := range map[string]string{
+		"app/docker/conf/nginx/site.conf": "server {}",
+		"app/docker/conf/mime.types":      "types {}",
+		"app/docker/sql/init.sql":         "CREATE TABLE t;",
+		"app/docker/.env.example":         "KEY=value",
+		"app/ui/images/home.png":          "png",
+	} {
+		got, err := os.ReadFile(filepath.Join(appDir, dest))
+		if err != nil || string(got) != content {
+			t.Errorf("%s = %q, %v, want %q", dest, got, err, content)
+		}
+	}
+	if exists(filepath.Join(appDir, "app/ui/images/notes.txt")) {
+		t.Error("files not matching the pattern must not be included")
+	}
+
+	info, err := os.Stat(filepath.Join(appDir, "app/docker/sql/init.sql"))
+	if err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("permissions of init.sql = %v, %v, want 0750", info.Mode().Perm(), err)
+	}
+}
+
+func TestCopyIncludesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		err     string
+	}{
+		{"no match", "missing/*", "matches no files"},
+		{"several files to a file", "conf/* -> app/docker/site.conf", "must be a directory"},
+		{"pattern outside input", "../outside/*", "is invalid"},
+		{"destination outside package", "conf/* -> ../escape/", "must be inside the package"},
+		{"symlink outside input", "linked/*", "links outside the input directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestApp(t, map[string]string{
+				"compose.yaml": strings.Replace(testCompose, "x-fnpack:\n", "x-fnpack:\n  include:\n    - "+tt.include+"\n", 1),
+				"conf/a.conf":  "",
+				"conf/b.conf":  "",
+			})
+			outside := filepath.Join(filepath.Dir(b.InputDir), "outside")
+			writeFiles(t, outside, map[string]string{"secret": "outside"})
+			if err := os.MkdirAll(filepath.Join(b.InputDir, "linked"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(b.InputDir, "linked", "secret")); err != nil {
+				t.Fatal(err)
+			}
+
+			err := b.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Build error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCopyIncludesSymlinkInside(t *testing.T) {
+	b := newTestApp(t, map[string]string{
+		"compose.yaml": strings.Replace(testCompose, "x-fnpack:\n", "x-fnpack:\n  include:\n    - site.conf\n", 1),
+		"conf/a.conf":  "server {}",
+	})
+	if err := os.Symlink(filepath.Join("conf", "a.conf"), filepath.Join(b.InputDir, "site.conf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(b.GetAppDir(), "app/docker/site.conf")); string(got) != "server {}" {
+		t.Errorf("site.conf = %q, want the link target content", got)
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	tests := map[string]string{
+		"conf/**":              "conf",
+		"conf/nginx/*.conf":    "conf/nginx",
+		"*.sql":                "",
+		"a/{b,c}/d":            "a",
+		"env.tpl":              "",
+		"screenshots/home.png": "screenshots",
+	}
+	for pattern, want := range tests {
+		if got := staticPrefix(pattern); got != want {
+			t.Errorf("staticPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestValidateBindMounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		volume string
+		err    string
+	}{
+		{"included source", "./conf/nginx:/etc/nginx/conf.d", ""},
+		{"missing source", "./data:/data", "bind mount source ./data is not in the package"},
+		{"outside package", "../../../etc:/host-etc", "points outside the package"},
+		{"named volume", "data:/data", ""},
+		{"absolute path", "/var/run/docker.sock:/var/run/docker.sock:ro", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compose := strings.Replace(includeCompose, "./conf/nginx:/etc/nginx/conf.d", tt.volume, 1)
+			b := newTestApp(t, map[string]string{
+				"compose.yaml":         compose,
+				"conf/nginx/site.conf": "server {}",
+				"sql/init.sql":         "",
+				"env.tpl":              "",
+				"screenshots/home.png": "",
+			})
+
+			err := b.Build()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("Build failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Build error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
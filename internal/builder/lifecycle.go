@@ -0,0 +1,156 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"fpk-compose-builder/internal/generator"
+)
+
+// scriptSections returns the generated sections of a cmd script, in run order
+// The exit trap is installed before the migration and the hooks, so that it
+// covers their failures
+func (b *Builder) scriptSections(script string) []string {
+	lifecycle := b.Compose.XFnpack.Lifecycle
+	restore := script == "upgrade_callback" && lifecycle.Backup
+	postHook := b.hooks[script].Post != ""
+	var sections []string
+
+	if secrets := b.secrets[script]; len(secrets) > 0 {
+		sections = append(sections, generator.MarkSection("secrets", generator.GenerateSecretsScript(secrets)))
+	}
+
+	switch script {
+	case "upgrade_init":
+		if lifecycle.Backup {
+			sections = append(sections, generator.MarkSection("backup", generator.GenerateBackupScript(b.backupPaths(), b.projectName())))
+		}
+	case "uninstall_init":
+		if lifecycle.StopOnUninstall {
+			sections = append(sections, generator.MarkSection("stop", generator.GenerateStopScript(b.projectName())))
+		}
+	case "uninstall_callback":
+		if lifecycle.Cleanup != "" {
+			sections = append(sections, generator.MarkSection("cleanup", generator.GenerateCleanupScript(lifecycle.Cleanup, b.projectName(), b.images())))
+		}
+	}
+
+	if restore {
+		sections = append(sections, generator.MarkSection("restore", generator.GenerateRestoreScript(b.backupPaths())))
+	}
+	if restore || postHook {
+		sections = append(sections, generator.MarkSection("exit", generator.GenerateExitScript(postHook, restore)))
+	}
+	if script == "upgrade_callback" && lifecycle.Migrate != "" {
+		sections = append(sections, generator.MarkSection("migrate", generator.GenerateMigrateScript(lifecycle.Migrate, b.projectName())))
+	}
+
+	return append(sections, b.hookSections(script)...)
+}
+
+// withSections inserts the generated sections of a cmd script before its body
+// and checks the shell syntax of the result
+func (b *Builder) withSections(script, content string) (string, error) {
+	sections := b.scriptSections(script)
+	if len(sections) > 0 {
+		if b.Verbose {
+			fmt.Printf("Generated sections in cmd/%s: %d\n", script, len(sections))
+		}
+		content = generator.InsertScriptFragment(content, strings.Join(sections, ""))
+	}
+
+	if err := checkShellSyntax("cmd/"+script, content); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+// backupPaths returns the directories archived before an upgrade
+// Defaults to the bind mount sources under ${TRIM_PKGVAR}, or ${TRIM_PKGVAR} itself
+func (b *Builder) backupPaths() []string {
+	if paths := b.Compose.XFnpack.Lifecycle.BackupPaths; len(paths) > 0 {
+		return paths
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, service := range b.Compose.Services {
+		for _, volume := range service.Volumes {
+			source, _, _ := strings.Cut(volume, ":")
+			if strings.HasPrefix(source, "${TRIM_PKGVAR}/") && !seen[source] {
+				seen[source] = true
+				paths = append(paths, source)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return []string{"${TRIM_PKGVAR}"}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// projectName returns the compose project name, the app name unless set in the compose file
+// Every generated docker compose call and the volume cleanup use it
+func (b *Builder) projectName() string {
+	if b.Compose.Name != "" {
+		return b.Compose.Name
+	}
+	return b.AppName
+}
+
+// images returns the images of the services, sorted
+func (b *Builder) images() []string {
+	seen := make(map[string]bool)
+	var images []string
+	for _, service := range b.Compose.Services {
+		if service.Image != "" && !seen[service.Image] {
+			seen[service.Image] = true
+			images = append(images, service.Image)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// lifecycleIssues checks the x-fnpack.lifecycle configuration
+func (b *Builder) lifecycleIssues() ([]string, error) {
+	var issues []string
+	lifecycle := b.Compose.XFnpack.Lifecycle
+
+	if field := lifecycle.Cleanup; field != "" {
+		content, ok := b.Compose.XFnpack.Files["wizard/uninstall"]
+		if !ok {
+			return append(issues, fmt.Sprintf("lifecycle.cleanup: wizard field %s needs a wizard/uninstall", field)), nil
+		}
+		fields, err := generator.WizardFields(content)
+		if err != nil {
+			return nil, fmt.Errorf("wizard/uninstall: %w", err)
+		}
+		found := false
+		for _, f := range fields {
+			if f == field {
+				found = true
+			}
+		}
+		if !found {
+			issues = append(issues, fmt.Sprintf("lifecycle.cleanup: wizard field %s is not defined in wizard/uninstall", field))
+		}
+	}
+
+	return issues, nil
+}
+
+// warnLifecycle prints the problems of the x-fnpack.lifecycle configuration
+func (b *Builder) warnLifecycle() error {
+	issues, err := b.lifecycleIssues()
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", issue)
+	}
+	return nil
+}
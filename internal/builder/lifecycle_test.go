@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLifecycleSectionsInPOSIXScripts(t *testing.T) {
+	b := newTestApp(t, map[string]string{
+		"compose.yaml": `x-fnpack:
+  manifest:
+    appname: demo
+  lifecycle:
+    backup: true
+  cmd/upgrade_init: |
+    #!/bin/sh
+    echo "upgrading"
+  cmd/upgrade_callback: |
+    #!/bin/sh
+    echo "upgraded"
+services:
+  web:
+    image: nginx:1.25
+    volumes:
+      - ${TRIM_PKGVAR}/data:/data
+`,
+	})
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(b.OutputDir, "demo", "cmd", "upgrade_init"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := string(content)
+	if !strings.Contains(init, "stop") || strings.Index(init, "stop") > strings.Index(init, "if ! fpk_backup") {
+		t.Errorf("upgrade_init must stop the containers before the backup:\n%s", init)
+	}
+
+	// Without migrate, any failure of upgrade_callback restores the backup
+	content, err = os.ReadFile(filepath.Join(b.OutputDir, "demo", "cmd", "upgrade_callback"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "trap 'fpk_on_exit \"$@\"' EXIT") || !strings.Contains(string(content), "restore_backup\n") {
+		t.Errorf("upgrade_callback does not restore the backup on failure:\n%s", content)
+	}
+}
@@ -0,0 +1,172 @@
+package builder
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// appArchiveName is the archive holding the app/ directory inside an .fpk file
+const appArchiveName = "app.tgz"
+
+// Package is the file content of a built package, keyed by slash-separated path
+// relative to the package root (e.g., "manifest", "wizard/install",
+// "app/docker/docker-compose.yaml")
+type Package struct {
+	// Source is the path the package was loaded from
+	Source string
+
+	// Files maps package paths to file content
+	Files map[string][]byte
+}
+
+// LoadPackage loads a package from an .fpk file, a generated app directory
+// or a standalone manifest file
+func LoadPackage(source string) (*Package, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package %s: %w", source, err)
+	}
+
+	pkg := &Package{Source: source, Files: make(map[string][]byte)}
+
+	switch {
+	case info.IsDir():
+		err = pkg.loadDir(source)
+	case strings.EqualFold(filepath.Ext(source), ".fpk"):
+		err = pkg.loadArchive(source)
+	default:
+		var content []byte
+		content, err = os.ReadFile(source)
+		pkg.Files["manifest"] = content
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package %s: %w", source, err)
+	}
+
+	return pkg, nil
+}
+
+// loadDir reads all files of a generated app directory
+func (p *Package) loadDir(dir string) error {
+	return filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		p.Files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+}
+
+// loadArchive reads an .fpk file (a tar archive, optionally gzip-compressed)
+// The nested app.tgz is expanded under "app/"
+func (p *Package) loadArchive(archivePath string) error {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+
+	files, err := readTar(data)
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		if name != appArchiveName {
+			p.Files[name] = content
+			continue
+		}
+		appFiles, err := readTar(content)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", appArchiveName, err)
+		}
+		for appName, appContent := range appFiles {
+			p.Files[path.Join("app", appName)] = appContent
+		}
+	}
+
+	return nil
+}
+
+// readTar reads the regular files of a tar archive, gunzipping it first if needed
+func readTar(data []byte) (map[string][]byte, error) {
+	var reader io.Reader = bytes.NewReader(data)
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(strings.TrimPrefix(header.Name, "./"))] = content
+	}
+
+	return files, nil
+}
+
+// Manifest parses the key=value manifest of the package
+func (p *Package) Manifest() map[string]string {
+	return ParseManifest(p.Files["manifest"])
+}
+
+// ParseManifest parses manifest content in key=value format
+func ParseManifest(content []byte) map[string]string {
+	manifest := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		manifest[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return manifest
+}
+
+// Compose returns the docker compose file of the package, if any
+func (p *Package) Compose() ([]byte, bool) {
+	content, ok := p.Files["app/docker/docker-compose.yaml"]
+	return content, ok
+}
@@ -0,0 +1,108 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"fpk-compose-builder/internal/generator"
+	"fpk-compose-builder/internal/parser"
+)
+
+// LoadAnswers reads a wizard answers fixture, a YAML map of wizard field to value
+func LoadAnswers(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answers: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, &SourceError{File: path, Err: fmt.Errorf("failed to parse answers: %w", err)}
+	}
+
+	answers := make(map[string]string, len(raw))
+	for field, value := range raw {
+		if value == nil {
+			answers[field] = ""
+		} else {
+			answers[field] = fmt.Sprint(value)
+		}
+	}
+	return answers, nil
+}
+
+// Render returns the compose file as fnOS runs it once the install wizard is
+// answered: the wizard values and the TRIM_* variables are substituted
+// Answers are checked against the rules of the wizard/install fields, missing
+// answers to optional fields default to the initValue of their field
+// The content is only returned when no answer violates a rule
+func (b *Builder) Render(answers map[string]string) ([]byte, []string, error) {
+	if err := b.parseCompose(); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compose: %w", err)
+	}
+
+	values := b.RuntimeEnv("")
+	for field, value := range answers {
+		values[field] = value
+	}
+
+	var problems []string
+	if content, ok := b.Compose.XFnpack.Files["wizard/install"]; ok {
+		items, err := generator.WizardItems(content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("wizard/install: %w", err)
+		}
+		for _, item := range items {
+			value, ok := answers[item.Field]
+			// A required field must be answered, its initValue is only a suggestion
+			if !ok && item.Required() {
+				problems = append(problems, fmt.Sprintf("%s: is required but missing from the answers", item.Field))
+				continue
+			}
+			if !ok && item.InitValue != nil {
+				value, ok = fmt.Sprint(item.InitValue), true
+				values[item.Field] = value
+			}
+			if !ok {
+				// fnOS passes unanswered optional fields as empty strings
+				values[item.Field] = ""
+			}
+			problems = append(problems, item.CheckValue(value)...)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems, nil
+	}
+
+	content, _, err := b.composeContent()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing := make(map[string]bool)
+	rendered, err := parser.InterpolateCompose(content, parser.InterpolateOptions{
+		Lookup: func(name string) (string, bool) {
+			value, ok := values[name]
+			return value, ok
+		},
+		Missing: func(name string) { missing[name] = true },
+		Runtime: true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render compose file: %w", err)
+	}
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "Warning: variable %s is not set, defaulting to an empty string\n", name)
+	}
+
+	return rendered, nil, nil
+}
@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// renderCompose is an app whose install wizard has a required and an optional field
+const renderCompose = `x-fnpack:
+  manifest:
+    appname: demo
+    version: "1.0.0"
+  wizard/install: |
+    [{"stepTitle":"Setup","items":[
+      {"type":"text","field":"wizard_user","initValue":"admin","rules":[{"required":true}]},
+      {"type":"text","field":"wizard_theme","initValue":"light"}
+    ]}]
+services:
+  web:
+    image: nginx:1.25
+    environment:
+      - USER=${wizard_user}
+      - THEME=${wizard_theme}
+`
+
+func TestRender(t *testing.T) {
+	b := newTestApp(t, map[string]string{"compose.yaml": renderCompose})
+	content, problems, err := b.Render(map[string]string{"wizard_user": "alice"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	// The optional field takes its initValue
+	for _, want := range []string{"USER=alice", "THEME=light"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("rendered compose does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestRenderMissingRequiredAnswer(t *testing.T) {
+	// The initValue of a required field does not stand in for an answer
+	b := newTestApp(t, map[string]string{"compose.yaml": renderCompose})
+	content, problems, err := b.Render(map[string]string{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := []string{"wizard_user: is required but missing from the answers"}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+	if content != nil {
+		t.Errorf("content = %q, want none with problems", content)
+	}
+}
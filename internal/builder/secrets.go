@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"fpk-compose-builder/internal/generator"
+	"fpk-compose-builder/internal/parser"
+)
+
+// loadSecrets collects the secret wizard fields by the callback script that writes them
+func (b *Builder) loadSecrets() error {
+	b.secrets = make(map[string][]parser.Secret)
+	for filePath, content := range b.Compose.XFnpack.Files {
+		script, ok := generator.WizardCallbacks[filePath]
+		if !ok {
+			continue
+		}
+		secrets, err := generator.WizardSecrets(content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		if len(secrets) > 0 {
+			b.secrets[script] = secrets
+		}
+	}
+	return nil
+}
+
+// allSecrets returns the secret wizard fields of all wizards, sorted by name
+func (b *Builder) allSecrets() []parser.Secret {
+	seen := make(map[string]bool)
+	var all []parser.Secret
+	for _, secrets := range b.secrets {
+		for _, secret := range secrets {
+			if !seen[secret.Name] {
+				seen[secret.Name] = true
+				all = append(all, secret)
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// applySecrets rewrites the compose content to read the secret wizard fields from files
+// Returns the content and the problems found, see parser.ApplySecrets
+func (b *Builder) applySecrets(data []byte) ([]byte, []string, error) {
+	secrets := b.allSecrets()
+	if len(secrets) == 0 {
+		return data, nil, nil
+	}
+	return parser.ApplySecrets(data, secrets)
+}
+
+// warnSecrets prints the secret problems found while writing the compose file
+func warnSecrets(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,213 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// scriptSource is where the content of a script is defined in a compose file
+// File is empty when the content does not come from a compose file
+type scriptSource struct {
+	File string
+	Line int
+}
+
+// position formats the location of a script line, mapped to the compose file if known
+func (s scriptSource) position(name string, line int) string {
+	if s.File == "" {
+		return fmt.Sprintf("%s:%d", name, line)
+	}
+	return fmt.Sprintf("%s:%d: %s", s.File, s.Line+line-1, name)
+}
+
+// sourceOf locates the x-fnpack value at keys (e.g. "cmd/main" or "hooks",
+// "main", "pre") in the compose files, later overlays take precedence
+// The line is the first content line, for literal blocks the line after the key
+func (b *Builder) sourceOf(keys ...string) scriptSource {
+	paths, err := b.ComposeFilePaths()
+	if err != nil {
+		return scriptSource{}
+	}
+
+	for i := len(paths) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			continue
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+			continue
+		}
+
+		node := doc.Content[0]
+		for _, key := range append([]string{"x-fnpack"}, keys...) {
+			node = mappingValue(node, key)
+			if node == nil {
+				break
+			}
+		}
+		if node == nil {
+			continue
+		}
+
+		line := node.Line
+		if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			line++
+		}
+		return scriptSource{File: paths[i], Line: line}
+	}
+	return scriptSource{}
+}
+
+// mappingValue returns the value of key in a YAML mapping node, nil if absent
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// checkScript checks the syntax of a user-supplied script and warns about
+// common pitfalls: a missing shebang (unless fragment is set, for hooks) and
+// unquoted $TRIM_* paths, which break on paths with spaces
+// Syntax errors of content defined in a compose file point to its line there
+func checkScript(name, content string, src scriptSource, fragment bool) error {
+	file, err := parseShell(name, content)
+	if err != nil {
+		line, ok := shellErrorLine(err)
+		if !ok || src.File == "" {
+			return fmt.Errorf("shell syntax error: %w", err)
+		}
+		return &SourceError{File: src.File, Line: src.Line + line - 1, Err: fmt.Errorf("shell syntax error: %w", err)}
+	}
+	if file == nil {
+		return nil
+	}
+
+	var warnings []string
+	if !fragment && shebangInterpreter(content) == "" {
+		warnings = append(warnings, fmt.Sprintf("%s: missing shebang, add #!/bin/bash as the first line", src.position(name, 1)))
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		var words []*syntax.Word
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			words = n.Args
+		case *syntax.Redirect:
+			words = []*syntax.Word{n.Word}
+		case *syntax.WordIter:
+			words = n.Items
+		}
+		for _, word := range words {
+			if word == nil {
+				continue
+			}
+			for _, part := range word.Parts {
+				param, ok := part.(*syntax.ParamExp)
+				if ok && param.Param != nil && strings.HasPrefix(param.Param.Value, "TRIM_") {
+					warnings = append(warnings, fmt.Sprintf("%s: unquoted $%s, quote it (\"$%s\") to support paths with spaces",
+						src.position(name, int(param.Pos().Line())), param.Param.Value, param.Param.Value))
+				}
+			}
+		}
+		return true
+	})
+
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return nil
+}
+
+// checkPackageScripts checks the files under cmd/ after x-fnpack.include was
+// copied, the x-fnpack scripts are checked with their compose lines when written
+func (b *Builder) checkPackageScripts() error {
+	cmdDir := filepath.Join(b.GetAppDir(), "cmd")
+	return filepath.WalkDir(cmdDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(b.GetAppDir(), path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if _, ok := b.Compose.XFnpack.Files[name]; ok {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return checkScript(name, string(content), scriptSource{}, false)
+	})
+}
+
+// checkShellSyntax parses a generated script, see parseShell
+func checkShellSyntax(name, content string) error {
+	if _, err := parseShell(name, content); err != nil {
+		return fmt.Errorf("shell syntax error: %w", err)
+	}
+	return nil
+}
+
+// parseShell parses a shell script, the dialect follows the shebang (POSIX
+// for sh, dash and ash, bash otherwise)
+// Scripts of other interpreters are skipped and return a nil file
+func parseShell(name, content string) (*syntax.File, error) {
+	variant := syntax.LangBash
+	switch shebangInterpreter(content) {
+	case "", "bash":
+	case "sh", "dash", "ash":
+		variant = syntax.LangPOSIX
+	default:
+		return nil, nil
+	}
+
+	parser := syntax.NewParser(syntax.Variant(variant))
+	return parser.Parse(strings.NewReader(content), name)
+}
+
+// shellErrorLine returns the line a shell parse error points to
+func shellErrorLine(err error) (int, bool) {
+	var parseErr syntax.ParseError
+	if errors.As(err, &parseErr) {
+		return int(parseErr.Pos.Line()), true
+	}
+	var langErr syntax.LangError
+	if errors.As(err, &langErr) {
+		return int(langErr.Pos.Line()), true
+	}
+	return 0, false
+}
+
+// shebangInterpreter returns the interpreter name of a script's shebang
+// ("bash" for "#!/usr/bin/env bash"), empty without a shebang
+func shebangInterpreter(content string) string {
+	firstLine, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(firstLine, "#!") {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(firstLine, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	return interpreter
+}
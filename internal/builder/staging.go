@@ -0,0 +1,180 @@
+package builder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// createStagingDir creates an empty staging directory next to the app directory
+// Being on the same filesystem, it can be renamed into place at the end of the build
+func (b *Builder) createStagingDir() error {
+	appDir := b.GetAppDir()
+	parent := filepath.Dir(appDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	stagingDir, err := os.MkdirTemp(parent, "."+filepath.Base(appDir)+".staging-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := os.Chmod(stagingDir, 0755); err != nil {
+		os.RemoveAll(stagingDir)
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	b.stagingDir = stagingDir
+	return nil
+}
+
+// removeStagingDir removes the staging directory of a failed build
+func (b *Builder) removeStagingDir() {
+	if b.stagingDir != "" {
+		os.RemoveAll(b.stagingDir)
+		b.stagingDir = ""
+	}
+}
+
+// swapStagingDir replaces the app directory with the staging directory
+// The previous app directory is moved aside first and restored if the rename fails
+func (b *Builder) swapStagingDir() error {
+	stagingDir := b.stagingDir
+	b.stagingDir = ""
+	appDir := b.GetAppDir()
+
+	if _, err := os.Stat(appDir); err == nil {
+		if b.Verbose {
+			stale, err := staleFiles(appDir, stagingDir)
+			if err != nil {
+				b.stagingDir = stagingDir
+				return err
+			}
+			for _, file := range stale {
+				fmt.Printf("Removed stale file: %s\n", file)
+			}
+		}
+
+		oldDir := stagingDir + ".old"
+		if err := os.Rename(appDir, oldDir); err != nil {
+			b.stagingDir = stagingDir
+			return err
+		}
+		if err := os.Rename(stagingDir, appDir); err != nil {
+			os.Rename(oldDir, appDir)
+			b.stagingDir = stagingDir
+			return err
+		}
+		return os.RemoveAll(oldDir)
+	}
+
+	if err := os.Rename(stagingDir, appDir); err != nil {
+		b.stagingDir = stagingDir
+		return err
+	}
+	return nil
+}
+
+// staleFiles lists the files of the previous app directory the new build no longer contains
+func staleFiles(oldDir, newDir string) ([]string, error) {
+	var stale []string
+	err := filepath.WalkDir(oldDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(oldDir, filePath)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(newDir, rel)); os.IsNotExist(err) {
+			stale = append(stale, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare app directories: %w", err)
+	}
+
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// CleanOutput removes the artifacts earlier builds of the app left in the
+// output directory (--clean): the app directory, leftover staging directories
+// and the .fpk files matching OutputName (<appname>.fpk and <appname>_*.fpk
+// without a template, so apps sharing the name as a prefix are not affected)
+// Other files are kept, and nothing that contains the input directory is removed
+func (b *Builder) CleanOutput() error {
+	if err := b.resolveAppName(); err != nil {
+		return err
+	}
+
+	fpkPatterns := []string{b.AppName + ".fpk", b.AppName + "_*.fpk"}
+	if b.OutputName != "" {
+		fpkPattern := strings.NewReplacer(
+			"{appname}", b.AppName,
+			"{version}", "*",
+			"{arch}", "*",
+		).Replace(b.OutputName)
+		if !strings.HasSuffix(fpkPattern, ".fpk") {
+			fpkPattern += ".fpk"
+		}
+		if _, err := filepath.Match(fpkPattern, ""); err != nil {
+			return fmt.Errorf("invalid output name %q: %w", b.OutputName, err)
+		}
+		fpkPatterns = []string{fpkPattern}
+	}
+
+	var targets []string
+	for _, pattern := range append(fpkPatterns, "."+b.AppName+".staging-*") {
+		matches, err := filepath.Glob(filepath.Join(b.OutputDir, pattern))
+		if err != nil {
+			return fmt.Errorf("failed to search output directory: %w", err)
+		}
+		targets = append(targets, matches...)
+	}
+	targets = append(targets, filepath.Join(b.OutputDir, b.AppName))
+
+	for _, target := range targets {
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clean output directory: %w", err)
+		}
+		if strings.HasSuffix(target, ".fpk") && !info.Mode().IsRegular() {
+			continue
+		}
+		if err := b.checkCleanTarget(target); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to clean output directory: %w", err)
+		}
+		if b.Verbose {
+			fmt.Printf("Removed: %s\n", target)
+		}
+	}
+
+	return nil
+}
+
+// checkCleanTarget refuses to remove a path that contains the input directory
+func (b *Builder) checkCleanTarget(target string) error {
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	absInput, err := filepath.Abs(b.InputDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if rel, err := filepath.Rel(absTarget, absInput); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to remove %s, it contains the input directory %s", target, b.InputDir)
+	}
+	return nil
+}
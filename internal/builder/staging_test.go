@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanOutput(t *testing.T) {
+	b := newTestApp(t, map[string]string{"compose.yaml": testCompose})
+	b.OutputName = "{appname}_{version}_{arch}.fpk"
+	writeFiles(t, b.OutputDir, map[string]string{
+		"demo/manifest":              "appname=demo\n",
+		"demo_0.9.0_x86_64.fpk":      "old",
+		".demo.staging-123/manifest": "",
+		"other_1.0.0_x86_64.fpk":     "other app",
+		"notes.txt":                  "foreign",
+		"demo-data/keep":             "foreign",
+	})
+
+	if err := b.CleanOutput(); err != nil {
+		t.Fatalf("CleanOutput failed: %v", err)
+	}
+
+	for _, removed := range []string{"demo", "demo_0.9.0_x86_64.fpk", ".demo.staging-123"} {
+		if exists(filepath.Join(b.OutputDir, removed)) {
+			t.Errorf("expected %s to be removed", removed)
+		}
+	}
+	for _, kept := range []string{"other_1.0.0_x86_64.fpk", "notes.txt", "demo-data/keep"} {
+		if !exists(filepath.Join(b.OutputDir, kept)) {
+			t.Errorf("expected foreign file %s to survive", kept)
+		}
+	}
+}
+
+func TestCleanOutputDefaultName(t *testing.T) {
+	// Without a template only the names fnpack gives the app are removed
+	b := newTestApp(t, map[string]string{"compose.yaml": testCompose})
+	writeFiles(t, b.OutputDir, map[string]string{
+		"demo.fpk":            "old",
+		"demo_0.9.0.fpk":      "old",
+		"demo-admin_1.0.fpk":  "other app",
+		"demoapp.fpk":         "other app",
+		"demo-admin/manifest": "appname=demo-admin\n",
+	})
+
+	if err := b.CleanOutput(); err != nil {
+		t.Fatalf("CleanOutput failed: %v", err)
+	}
+
+	for _, removed := range []string{"demo.fpk", "demo_0.9.0.fpk"} {
+		if exists(filepath.Join(b.OutputDir, removed)) {
+			t.Errorf("expected %s to be removed", removed)
+		}
+	}
+	for _, kept := range []string{"demo-admin_1.0.fpk", "demoapp.fpk", "demo-admin/manifest"} {
+		if !exists(filepath.Join(b.OutputDir, kept)) {
+			t.Errorf("expected %s of another app to survive", kept)
+		}
+	}
+}
+
+func TestCleanOutputRefusesInputDir(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "demo")
+	writeFiles(t, inputDir, map[string]string{"compose.yaml": testCompose})
+
+	// The app directory of the output would be the input directory itself
+	b := NewBuilder(inputDir, root, false)
+	if err := b.CleanOutput(); err == nil {
+		t.Fatal("expected an error cleaning the input directory")
+	}
+	if !exists(filepath.Join(inputDir, "compose.yaml")) {
+		t.Error("input directory was removed")
+	}
+}
+
+func TestSwapStagingDir(t *testing.T) {
+	b := newTestApp(t, map[string]string{
+		"compose.yaml": `x-fnpack:
+  manifest:
+    appname: demo
+  cmd/upgrade_init: |
+    #!/bin/bash
+    exit 0
+services:
+  web:
+    image: nginx:1.25
+`,
+	})
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	appDir := filepath.Join(b.OutputDir, "demo")
+	if !exists(filepath.Join(appDir, "cmd", "upgrade_init")) {
+		t.Fatal("expected cmd/upgrade_init in the first build")
+	}
+
+	// A file removed from x-fnpack does not linger after the swap
+	writeFiles(t, b.InputDir, map[string]string{"compose.yaml": testCompose})
+	if err := b.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(appDir, "cmd", "upgrade_init"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) == "#!/bin/bash\nexit 0\n" {
+		t.Error("stale cmd/upgrade_init survived the swap")
+	}
+	writeFiles(t, appDir, map[string]string{"marker": "previous build"})
+
+	// A failed build keeps the previous app directory and leaves no staging directory
+	writeFiles(t, b.InputDir, map[string]string{"compose.yaml": testCompose + `    volumes:
+      - ./missing:/data
+`})
+	if err := b.Build(); err == nil {
+		t.Fatal("expected the build to fail on a missing bind mount source")
+	}
+	if !exists(filepath.Join(appDir, "marker")) || !exists(filepath.Join(appDir, "manifest")) {
+		t.Error("failed build did not keep the previous app directory")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(b.OutputDir, ".demo.staging-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("staging directories left behind: %v", leftovers)
+	}
+}
@@ -0,0 +1,182 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"fpk-compose-builder/internal/generator"
+	"fpk-compose-builder/internal/parser"
+)
+
+// UpgradeReport is the result of comparing a build against a previous package
+type UpgradeReport struct {
+	// Errors block the upgrade (e.g., a version downgrade)
+	Errors []string
+
+	// Warnings are changes that break upgrades or orphan user data
+	Warnings []string
+}
+
+// CheckUpgrade compares the generated package against a previous package
+// (an .fpk file, an app directory or a manifest file) and reports changes
+// that prevent or break an upgrade on fnOS
+func (b *Builder) CheckUpgrade(previous string) (*UpgradeReport, error) {
+	oldPkg, err := LoadPackage(previous)
+	if err != nil {
+		return nil, err
+	}
+	newPkg, err := LoadPackage(b.GetAppDir())
+	if err != nil {
+		return nil, err
+	}
+
+	return ComparePackages(oldPkg, newPkg)
+}
+
+// ComparePackages checks the upgrade path from oldPkg to newPkg
+func ComparePackages(oldPkg, newPkg *Package) (*UpgradeReport, error) {
+	report := &UpgradeReport{}
+	oldManifest := oldPkg.Manifest()
+	newManifest := newPkg.Manifest()
+
+	// The version must increase, fnOS refuses to install a lower version over a higher one
+	// Versions that are not dotted numeric versions can only be checked for equality
+	oldVersion, newVersion := oldManifest["version"], newManifest["version"]
+	cmp, err := generator.CompareVersions(newVersion, oldVersion)
+	switch {
+	case err != nil && newVersion == oldVersion:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("version %s is the same as the previous version", newVersion))
+	case err != nil:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("cannot check that version %s is higher than the previous version %s: %v",
+			newVersion, oldVersion, err))
+	case cmp < 0:
+		report.Errors = append(report.Errors, fmt.Sprintf("version %s is lower than the previous version %s", newVersion, oldVersion))
+	case cmp == 0:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("version %s is the same as the previous version", newVersion))
+	}
+
+	// A different appname installs as a separate app instead of upgrading
+	if oldManifest["appname"] != newManifest["appname"] {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("appname changed from %s to %s, the package will not upgrade the installed app",
+			oldManifest["appname"], newManifest["appname"]))
+	}
+
+	report.Warnings = append(report.Warnings, compareWizardFields(oldPkg, newPkg)...)
+
+	volumeWarnings, err := compareDataVolumes(oldPkg, newPkg)
+	if err != nil {
+		return nil, err
+	}
+	report.Warnings = append(report.Warnings, volumeWarnings...)
+
+	return report, nil
+}
+
+// compareWizardFields reports wizard fields of the previous package missing from the new one
+func compareWizardFields(oldPkg, newPkg *Package) []string {
+	var warnings []string
+
+	for filePath, content := range oldPkg.Files {
+		if !generator.IsWizardFile(filePath) {
+			continue
+		}
+		oldFields, err := generator.WizardFields(string(content))
+		if err != nil {
+			continue
+		}
+
+		newFields := make(map[string]bool)
+		if newContent, ok := newPkg.Files[filePath]; ok {
+			fields, _ := generator.WizardFields(string(newContent))
+			for _, field := range fields {
+				newFields[field] = true
+			}
+		}
+
+		for _, field := range oldFields {
+			if !newFields[field] {
+				warnings = append(warnings, fmt.Sprintf("%s: wizard field %s was removed", filePath, field))
+			}
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings
+}
+
+// compareDataVolumes reports volume mounts whose source changed or that were removed
+// Data stored at the old source would no longer be mounted after the upgrade
+func compareDataVolumes(oldPkg, newPkg *Package) ([]string, error) {
+	oldCompose, ok := oldPkg.Compose()
+	if !ok {
+		return nil, nil
+	}
+	oldMounts, err := volumeMounts(oldCompose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse previous compose file: %w", err)
+	}
+
+	newMounts := make(map[string]string)
+	if newCompose, ok := newPkg.Compose(); ok {
+		if newMounts, err = volumeMounts(newCompose); err != nil {
+			return nil, fmt.Errorf("failed to parse compose file: %w", err)
+		}
+	}
+
+	var warnings []string
+	for target, oldSource := range oldMounts {
+		newSource, ok := newMounts[target]
+		switch {
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("volume %s (%s) was removed, its data would be orphaned", target, oldSource))
+		case newSource != oldSource:
+			warnings = append(warnings, fmt.Sprintf("volume %s changed from %s to %s, existing data would be orphaned", target, oldSource, newSource))
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings, nil
+}
+
+// volumeMounts maps "<service>:<container path>" to the mount source of every volume
+func volumeMounts(composeContent []byte) (map[string]string, error) {
+	compose, err := parser.ParseComposeContent(composeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[string]string)
+	for serviceName, service := range compose.Services {
+		for _, volume := range service.Volumes {
+			parts := strings.Split(volume, ":")
+			if len(parts) < 2 {
+				// Anonymous volumes hold no user data across recreation
+				continue
+			}
+			mounts[serviceName+":"+parts[1]] = parts[0]
+		}
+	}
+	return mounts, nil
+}
+
+// checkPrevious runs the upgrade checks against b.Previous and prints the findings
+func (b *Builder) checkPrevious() error {
+	report, err := b.CheckUpgrade(b.Previous)
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("upgrade check failed: %s", strings.Join(report.Errors, "; "))
+	}
+
+	if b.Verbose {
+		fmt.Printf("Upgrade check passed against: %s\n", b.Previous)
+	}
+	return nil
+}
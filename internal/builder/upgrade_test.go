@@ -0,0 +1,238 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPackage creates a package from a manifest, a compose file and extra files
+func testPackage(manifest, compose string, files map[string]string) *Package {
+	pkg := &Package{Files: map[string][]byte{"manifest": []byte(manifest)}}
+	if compose != "" {
+		pkg.Files["app/docker/docker-compose.yaml"] = []byte(compose)
+	}
+	for name, content := range files {
+		pkg.Files[name] = []byte(content)
+	}
+	return pkg
+}
+
+// testWizard is an install wizard with the fields wizard_user and wizard_port
+const testWizard = `[{"stepTitle":"Setup","items":[
+	{"type":"text","field":"wizard_user"},
+	{"type":"text","field":"wizard_port"}
+]}]`
+
+func TestComparePackages(t *testing.T) {
+	const manifest = "appname=demo\nversion=1.0.0\n"
+
+	tests := []struct {
+		name        string
+		oldManifest string
+		newManifest string
+		oldFiles    map[string]string
+		newFiles    map[string]string
+		errors      []string
+		warnings    []string
+	}{
+		{
+			name:        "upgrade",
+			oldManifest: manifest,
+			newManifest: "appname=demo\nversion=1.1.0\n",
+		},
+		{
+			name:        "downgrade",
+			oldManifest: "appname=demo\nversion=1.1.0\n",
+			newManifest: manifest,
+			errors:      []string{"version 1.0.0 is lower than the previous version 1.1.0"},
+		},
+		{
+			name:        "same version",
+			oldManifest: manifest,
+			newManifest: manifest,
+			warnings:    []string{"version 1.0.0 is the same as the previous version"},
+		},
+		{
+			name:        "previous version not comparable",
+			oldManifest: "appname=demo\nversion=2024.05.01-r1_beta\n",
+			newManifest: manifest,
+			warnings: []string{`cannot check that version 1.0.0 is higher than the previous version 2024.05.01-r1_beta: ` +
+				`invalid version "2024.05.01-r1_beta" (expected e.g. 1.2.3 or v1.2.3-beta.1)`},
+		},
+		{
+			name:        "same version not comparable",
+			oldManifest: "appname=demo\nversion=latest\n",
+			newManifest: "appname=demo\nversion=latest\n",
+			warnings:    []string{"version latest is the same as the previous version"},
+		},
+		{
+			name:        "appname change",
+			oldManifest: manifest,
+			newManifest: "appname=demo2\nversion=1.1.0\n",
+			warnings:    []string{"appname changed from demo to demo2, the package will not upgrade the installed app"},
+		},
+		{
+			name:        "removed wizard field",
+			oldManifest: manifest,
+			newManifest: "appname=demo\nversion=1.1.0\n",
+			oldFiles:    map[string]string{"wizard/install": testWizard, "wizard/config": testWizard},
+			newFiles: map[string]string{"wizard/install": `[{"stepTitle":"Setup","items":[
+				{"type":"text","field":"wizard_user"}]}]`},
+			warnings: []string{
+				"wizard/config: wizard field wizard_port was removed",
+				"wizard/config: wizard field wizard_user was removed",
+				"wizard/install: wizard field wizard_port was removed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldPkg := testPackage(tt.oldManifest, "", tt.oldFiles)
+			newPkg := testPackage(tt.newManifest, "", tt.newFiles)
+
+			report, err := ComparePackages(oldPkg, newPkg)
+			if err != nil {
+				t.Fatalf("ComparePackages failed: %v", err)
+			}
+			if !reflect.DeepEqual(report.Errors, tt.errors) {
+				t.Errorf("errors = %q, want %q", report.Errors, tt.errors)
+			}
+			if !reflect.DeepEqual(report.Warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", report.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestCompareDataVolumes(t *testing.T) {
+	const oldCompose = `services:
+  web:
+    image: nginx
+    volumes:
+      - /vol1/demo/data:/data
+      - /vol1/demo/conf:/etc/app:ro
+      - cache:/cache
+      - /tmp
+`
+
+	tests := []struct {
+		name       string
+		newCompose string
+		warnings   []string
+	}{
+		{
+			name:       "unchanged",
+			newCompose: oldCompose,
+		},
+		{
+			name: "read-only flag dropped",
+			newCompose: `services:
+  web:
+    image: nginx
+    volumes:
+      - /vol1/demo/data:/data:rw
+      - /vol1/demo/conf:/etc/app
+      - cache:/cache
+`,
+		},
+		{
+			name: "changed sources",
+			newCompose: `services:
+  web:
+    image: nginx
+    volumes:
+      - /vol1/demo/storage:/data
+      - /vol1/demo/config:/etc/app:ro
+      - cache2:/cache
+`,
+			warnings: []string{
+				"volume web:/cache changed from cache to cache2, existing data would be orphaned",
+				"volume web:/data changed from /vol1/demo/data to /vol1/demo/storage, existing data would be orphaned",
+				"volume web:/etc/app changed from /vol1/demo/conf to /vol1/demo/config, existing data would be orphaned",
+			},
+		},
+		{
+			name: "removed mounts",
+			newCompose: `services:
+  web:
+    image: nginx
+    volumes:
+      - /vol1/demo/data:/data
+`,
+			warnings: []string{
+				"volume web:/cache (cache) was removed, its data would be orphaned",
+				"volume web:/etc/app (/vol1/demo/conf) was removed, its data would be orphaned",
+			},
+		},
+		{
+			name: "renamed service",
+			newCompose: `services:
+  app:
+    image: nginx
+    volumes:
+      - /vol1/demo/data:/data
+`,
+			warnings: []string{
+				"volume web:/cache (cache) was removed, its data would be orphaned",
+				"volume web:/data (/vol1/demo/data) was removed, its data would be orphaned",
+				"volume web:/etc/app (/vol1/demo/conf) was removed, its data would be orphaned",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := compareDataVolumes(testPackage("", oldCompose, nil), testPackage("", tt.newCompose, nil))
+			if err != nil {
+				t.Fatalf("compareDataVolumes failed: %v", err)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestCompareDataVolumesWithoutCompose(t *testing.T) {
+	// A previous package without a compose file has no data to orphan
+	warnings, err := compareDataVolumes(testPackage("", "", nil), testPackage("", "services: {}\n", nil))
+	if err != nil || warnings != nil {
+		t.Errorf("compareDataVolumes = %q, %v", warnings, err)
+	}
+
+	// Every mount is reported when the compose file was dropped
+	oldPkg := testPackage("", "services:\n  web:\n    image: nginx\n    volumes:\n      - ./data:/data\n", nil)
+	warnings, err = compareDataVolumes(oldPkg, testPackage("", "", nil))
+	if err != nil || len(warnings) != 1 || !strings.Contains(warnings[0], "web:/data (./data) was removed") {
+		t.Errorf("compareDataVolumes = %q, %v", warnings, err)
+	}
+}
+
+func TestVolumeMounts(t *testing.T) {
+	mounts, err := volumeMounts([]byte(`services:
+  web:
+    image: nginx
+    volumes:
+      - /data
+      - ./data:/data
+      - /vol1/conf:/etc/app:ro
+  db:
+    image: postgres
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+`))
+	if err != nil {
+		t.Fatalf("volumeMounts failed: %v", err)
+	}
+
+	want := map[string]string{
+		"web:/data":                   "./data",
+		"web:/etc/app":                "/vol1/conf",
+		"db:/var/lib/postgresql/data": "pgdata",
+	}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("volumeMounts = %v, want %v", mounts, want)
+	}
+}
@@ -0,0 +1,63 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fpk-compose-builder/internal/generator"
+)
+
+// Validate parses the compose file and checks the x-fnpack configuration
+// without writing anything. Returns the list of problems found; the error
+// is only set when the compose file itself cannot be parsed.
+func (b *Builder) Validate() ([]string, error) {
+	if err := b.parseCompose(); err != nil {
+		return nil, fmt.Errorf("failed to parse compose: %w", err)
+	}
+
+	var issues []string
+	xfnpack := b.Compose.XFnpack
+
+	// UI entries must have the fields their mode requires
+	if _, err := generator.GenerateUIConfig(b.UIEntries, b.Compose.Services, b.Variables, b.AppName, xfnpack.I18n); err != nil {
+		issues = append(issues, err.Error())
+	}
+
+	// Every localized string must provide all locales
+	missing, err := generator.MissingTranslations(b.Compose, b.UIEntries)
+	if err != nil {
+		issues = append(issues, err.Error())
+	}
+	issues = append(issues, missing...)
+
+	// cmd scripts must parse, pitfalls are printed as warnings
+	var scripts []string
+	for filePath := range xfnpack.Files {
+		if strings.HasPrefix(filePath, "cmd/") {
+			scripts = append(scripts, filePath)
+		}
+	}
+	sort.Strings(scripts)
+	for _, filePath := range scripts {
+		if err := checkScript(filePath, xfnpack.Files[filePath], b.sourceOf(filePath), false); err != nil {
+			issues = append(issues, err.Error())
+		}
+	}
+
+	// Lifecycle behaviors must refer to existing wizard fields
+	lifecycle, err := b.lifecycleIssues()
+	if err != nil {
+		issues = append(issues, err.Error())
+	}
+	issues = append(issues, lifecycle...)
+
+	// Secret wizard fields must be used and leave the environment
+	if _, warnings, err := b.composeContent(); err != nil {
+		issues = append(issues, err.Error())
+	} else {
+		issues = append(issues, warnings...)
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,95 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fpk-compose-builder/internal/generator"
+)
+
+// Version sources of x-fnpack.version_from
+const (
+	VersionFromGitTag   = "git-tag"
+	VersionFromImageTag = "image-tag"
+	VersionFromFile     = "file"
+)
+
+// versionFileName is the file read by version_from: file
+const versionFileName = "VERSION"
+
+// resolveVersion determines the package version and writes it back to the manifest
+// Priority: --version > x-fnpack.version_from > manifest.version > default
+// Snapshot builds (--snapshot, or git-tag with commits after the tag) get build metadata appended
+// Overridden and derived versions must be valid, a plain manifest version that
+// is not only warns and is kept as written
+func (b *Builder) resolveVersion() error {
+	manifest := b.Compose.XFnpack.Manifest
+	version := generator.GetManifestVersion(manifest)
+	snapshot := b.Snapshot
+	derived := b.VersionOverride != "" || b.Compose.XFnpack.VersionFrom != ""
+	var metadata []string
+
+	switch {
+	case b.VersionOverride != "":
+		version = b.VersionOverride
+
+	case b.Compose.XFnpack.VersionFrom == VersionFromGitTag:
+		tag, distance, head, err := latestVersionTag(b.InputDir, generator.IsVersion)
+		if err != nil {
+			return err
+		}
+		version = tag
+		if distance > 0 {
+			snapshot = true
+			metadata = []string{strconv.Itoa(distance), "g" + head}
+		}
+
+	case b.Compose.XFnpack.VersionFrom == VersionFromImageTag:
+		if b.Variables.ImageTag == "" {
+			return fmt.Errorf("image of service %s has no tag to derive the version from", b.Variables.ServiceName)
+		}
+		version = b.Variables.ImageTag
+
+	case b.Compose.XFnpack.VersionFrom == VersionFromFile:
+		content, err := os.ReadFile(filepath.Join(b.InputDir, versionFileName))
+		if err != nil {
+			return fmt.Errorf("failed to read version file: %w", err)
+		}
+		version = strings.TrimSpace(string(content))
+
+	case b.Compose.XFnpack.VersionFrom != "":
+		return fmt.Errorf("unsupported version source %q (expected %s, %s or %s)",
+			b.Compose.XFnpack.VersionFrom, VersionFromGitTag, VersionFromImageTag, VersionFromFile)
+	}
+
+	if normalized, err := generator.NormalizeVersion(version); err == nil {
+		version = normalized
+	} else if derived {
+		return err
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: manifest %v, kept as-is\n", err)
+	}
+
+	if snapshot {
+		if len(metadata) == 0 {
+			metadata = []string{"snapshot", time.Now().UTC().Format("20060102150405")}
+		}
+		version = generator.AppendBuildMetadata(version, metadata...)
+	}
+
+	if b.Compose.XFnpack.Manifest == nil {
+		b.Compose.XFnpack.Manifest = make(map[string]interface{})
+	}
+	b.Compose.XFnpack.Manifest["version"] = version
+	b.Version = version
+
+	if b.Verbose {
+		fmt.Printf("Version: %s\n", version)
+	}
+
+	return nil
+}
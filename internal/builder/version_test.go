@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveVersion(t *testing.T) {
+	compose := strings.Replace(testCompose, `version: "1.0.0"`, `version: "2024.10.18-r1_beta"`, 1)
+
+	tests := []struct {
+		name     string
+		override string
+		expected string
+		wantErr  bool
+	}{
+		{name: "plain manifest version is kept", expected: "2024.10.18-r1_beta"},
+		{name: "override is normalized", override: "v1.2.3", expected: "1.2.3"},
+		{name: "invalid override", override: "latest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestApp(t, map[string]string{"compose.yaml": compose})
+			b.VersionOverride = tt.override
+			if err := b.parseCompose(); err != nil {
+				t.Fatalf("parseCompose failed: %v", err)
+			}
+
+			err := b.resolveVersion()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveVersion failed: %v", err)
+			}
+			if b.Version != tt.expected || b.Compose.XFnpack.Manifest["version"] != tt.expected {
+				t.Errorf("version = %q, expected %q", b.Version, tt.expected)
+			}
+		})
+	}
+
+	// The non-semver version still builds
+	b := newTestApp(t, map[string]string{"compose.yaml": compose})
+	if err := b.Build(); err != nil {
+		t.Errorf("Build failed: %v", err)
+	}
+}
@@ -25,6 +25,7 @@ func (w *Writer) WriteManifest() error {
 	content := generator.GenerateManifest(
 		w.builder.Compose.XFnpack.Manifest,
 		w.builder.Variables,
+		w.builder.Compose.XFnpack.I18n,
 	)
 
 	manifestPath := filepath.Join(w.builder.GetAppDir(), "manifest")
@@ -88,7 +89,10 @@ func (w *Writer) WriteScript() error {
 
 	// Write main script if not provided
 	if !w.hasFile(files, "cmd/main") {
-		content := generator.GenerateMainScript(w.builder.Variables)
+		content, err := w.builder.withSections("main", generator.GenerateMainScript(w.builder.Variables))
+		if err != nil {
+			return err
+		}
 
 		scriptPath := filepath.Join(w.builder.GetAppDir(), "cmd", "main")
 		if err := os.WriteFile(scriptPath, []byte(content), 0755); err != nil {
@@ -105,6 +109,10 @@ func (w *Writer) WriteScript() error {
 	for name, content := range lifecycleScripts {
 		filePath := "cmd/" + name
 		if !w.hasFile(files, filePath) {
+			content, err := w.builder.withSections(name, content)
+			if err != nil {
+				return err
+			}
 			scriptPath := filepath.Join(w.builder.GetAppDir(), "cmd", name)
 			if err := os.WriteFile(scriptPath, []byte(content), 0755); err != nil {
 				return fmt.Errorf("failed to write %s script: %w", name, err)
@@ -121,11 +129,12 @@ func (w *Writer) WriteScript() error {
 
 // WriteUIConfig writes the app/ui/config file (default)
 // Only writes if not provided in x-fnpack files
+// Generates one entry per web-facing service, or a single entry for the first port
 func (w *Writer) WriteUIConfig() error {
 	files := w.builder.Compose.XFnpack.Files
 
 	if !w.hasFile(files, "app/ui/config") {
-		content, err := generator.GenerateDefaultUIConfig(w.builder.Variables, w.builder.AppName)
+		content, err := generator.GenerateUIConfig(w.builder.UIEntries, w.builder.Compose.Services, w.builder.Variables, w.builder.AppName, w.builder.Compose.XFnpack.I18n)
 		if err != nil {
 			return fmt.Errorf("failed to generate UI config: %w", err)
 		}
@@ -143,18 +152,14 @@ func (w *Writer) WriteUIConfig() error {
 	return nil
 }
 
-// CopyCompose copies the compose.yaml to app/docker/ with x-fnpack removed
+// CopyCompose writes the flattened compose file to app/docker/ with x-fnpack removed
+// Build-time variables are interpolated, runtime variables (TRIM_*, wizard_*) are kept
 func (w *Writer) CopyCompose() error {
-	composePath, err := FindComposeFile(w.builder.InputDir)
+	cleanContent, warnings, err := w.builder.composeContent()
 	if err != nil {
 		return err
 	}
-
-	// Clean the compose content (remove x-fnpack)
-	cleanContent, err := parser.CleanComposeFile(composePath)
-	if err != nil {
-		return fmt.Errorf("failed to clean compose file: %w", err)
-	}
+	warnSecrets(warnings)
 
 	// Write to app/docker/docker-compose.yaml
 	destPath := filepath.Join(w.builder.GetAppDir(), "app", "docker", "docker-compose.yaml")
@@ -235,6 +240,7 @@ func (w *Writer) WriteLicense() error {
 
 // WriteCustomFiles writes all files defined in x-fnpack (except manifest)
 // Files are written directly with variable replacement
+// Wizard files with localized strings are written once per locale
 func (w *Writer) WriteCustomFiles() error {
 	files := w.builder.Compose.XFnpack.Files
 	if files == nil {
@@ -242,32 +248,105 @@ func (w *Writer) WriteCustomFiles() error {
 	}
 
 	for filePath, content := range files {
-		// Replace variables in content
-		content = generator.ReplaceVariables(content, w.builder.Variables)
-
-		// Create full path
-		fullPath := filepath.Join(w.builder.GetAppDir(), filePath)
+		if generator.IsWizardFile(filePath) {
+			if err := w.writeLocalizedWizard(filePath, content); err != nil {
+				return err
+			}
+			continue
+		}
 
-		// Ensure parent directory exists
-		parentDir := filepath.Dir(fullPath)
-		if err := os.MkdirAll(parentDir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory for %s: %w", filePath, err)
+		if err := w.writeCustomFile(filePath, content); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+// writeLocalizedWizard writes a wizard file with localized strings resolved
+// The default locale goes to the file itself, other locales to "<file>_<locale>"
+// The builder-only "secret" keys are removed first
+func (w *Writer) writeLocalizedWizard(filePath, content string) error {
+	i18n := w.builder.Compose.XFnpack.I18n
+
+	content, _, err := generator.StripWizardSecrets(content)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+
+	localized, ok, err := generator.LocalizeWizard(content, i18n.Default(), i18n)
+	if err != nil {
+		return fmt.Errorf("failed to localize %s: %w", filePath, err)
+	}
+	if !ok {
+		return w.writeCustomFile(filePath, content)
+	}
+	if err := w.writeCustomFile(filePath, localized); err != nil {
+		return err
+	}
 
-		// Determine file permissions (executable for cmd/* files)
-		perm := os.FileMode(0644)
-		if strings.HasPrefix(filePath, "cmd/") {
-			perm = 0755
+	translations, err := generator.WizardTranslations(content)
+	if err != nil {
+		return fmt.Errorf("failed to localize %s: %w", filePath, err)
+	}
+	locales := make(map[string]string)
+	for _, values := range translations {
+		for _, locale := range generator.TargetLocales(values, i18n) {
+			locales[locale] = ""
 		}
+	}
 
-		// Write file
-		if err := os.WriteFile(fullPath, []byte(content), perm); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	for _, locale := range parser.SortedLocales(locales) {
+		localized, _, err := generator.LocalizeWizard(content, locale, i18n)
+		if err != nil {
+			return fmt.Errorf("failed to localize %s: %w", filePath, err)
+		}
+		if err := w.writeCustomFile(generator.LocalizedName(filePath, locale), localized); err != nil {
+			return err
 		}
+	}
 
-		if w.builder.Verbose {
-			fmt.Printf("Written: %s\n", fullPath)
+	return nil
+}
+
+// writeCustomFile writes a single x-fnpack file with variable replacement
+func (w *Writer) writeCustomFile(filePath, content string) error {
+	// Replace variables in content
+	content = generator.ReplaceVariables(content, w.builder.Variables)
+
+	// Generated sections (secrets, lifecycle behaviors, hooks) run before the script body
+	if script, ok := strings.CutPrefix(filePath, "cmd/"); ok {
+		if err := checkScript(filePath, content, w.builder.sourceOf(filePath), false); err != nil {
+			return err
 		}
+		var err error
+		if content, err = w.builder.withSections(script, content); err != nil {
+			return err
+		}
+	}
+
+	// Create full path
+	fullPath := filepath.Join(w.builder.GetAppDir(), filePath)
+
+	// Ensure parent directory exists
+	parentDir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", filePath, err)
+	}
+
+	// Determine file permissions (executable for cmd/* files)
+	perm := os.FileMode(0644)
+	if strings.HasPrefix(filePath, "cmd/") {
+		perm = 0755
+	}
+
+	// Write file
+	if err := os.WriteFile(fullPath, []byte(content), perm); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+
+	if w.builder.Verbose {
+		fmt.Printf("Written: %s\n", fullPath)
 	}
 
 	return nil
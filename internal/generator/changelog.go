@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultChangelogMaxLength is the changelog length limit when none is configured
+const DefaultChangelogMaxLength = 1000
+
+// changelogHeading matches keep-a-changelog release headings
+// e.g., "## [1.2.3] - 2024-01-01", "## 1.2.3", "## v1.2.3"
+var changelogHeading = regexp.MustCompile(`^##\s+\[?v?([^\]\s]+)\]?`)
+
+// ExtractChangelogSection returns the body of the release section for version
+// from a keep-a-changelog formatted document
+// Build metadata ("+...") is ignored when matching the version
+func ExtractChangelogSection(content, version string) (string, bool) {
+	version = strings.TrimPrefix(version, "v")
+	version, _, _ = strings.Cut(version, "+")
+
+	var section []string
+	inSection := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		// Any level-2 heading ends the current section
+		if strings.HasPrefix(line, "## ") {
+			if inSection {
+				break
+			}
+			if match := changelogHeading.FindStringSubmatch(line); match != nil && match[1] == version {
+				inSection = true
+			}
+			continue
+		}
+
+		if inSection {
+			section = append(section, line)
+		}
+	}
+
+	if !inSection {
+		return "", false
+	}
+
+	return strings.TrimSpace(strings.Join(section, "\n")), true
+}
+
+// FormatChangelog drops blank lines and truncates the changelog to maxLength characters,
+// ending it with "..." when the limit leaves room for it
+// Newlines are kept; they are escaped when the manifest line is written
+func FormatChangelog(text string, maxLength int) string {
+	if maxLength <= 0 {
+		maxLength = DefaultChangelogMaxLength
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRight(line, " \t\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	text = strings.Join(lines, "\n")
+
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	// Limits too short for the ellipsis are cut without it
+	const ellipsis = "..."
+	runes := []rune(text)
+	if maxLength <= len(ellipsis) {
+		return string(runes[:maxLength])
+	}
+	return strings.TrimRight(string(runes[:maxLength-len(ellipsis)]), " \n") + ellipsis
+}
@@ -0,0 +1,509 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+func TestExtractChangelogSection(t *testing.T) {
+	content := `# Changelog
+
+## [Unreleased]
+- Pending
+
+## [1.2.0] - 2024-02-01
+### Added
+- Multiple UI entries
+
+## [1.1.0] - 2024-01-01
+- Initial
+`
+
+	section, ok := ExtractChangelogSection(content, "1.2.0")
+	if !ok {
+		t.Fatal("Expected section for 1.2.0")
+	}
+	if section != "### Added\n- Multiple UI entries" {
+		t.Errorf("Unexpected section: %q", section)
+	}
+
+	if _, ok := ExtractChangelogSection(content, "9.9.9"); ok {
+		t.Error("Expected no section for 9.9.9")
+	}
+}
+
+func TestFormatChangelog(t *testing.T) {
+	if got := FormatChangelog("- a\n\n- b\n", 0); got != "- a\n- b" {
+		t.Errorf("FormatChangelog dropped lines incorrectly: %q", got)
+	}
+
+	got := FormatChangelog(strings.Repeat("更新", 20), 10)
+	if got != strings.Repeat("更新", 3)+"更..." {
+		t.Errorf("FormatChangelog truncated incorrectly: %q", got)
+	}
+
+	// Limits without room for the ellipsis are respected
+	for maxLength, expected := range map[int]string{1: "更", 2: "更新", 3: "更新更"} {
+		if got := FormatChangelog(strings.Repeat("更新", 20), maxLength); got != expected {
+			t.Errorf("FormatChangelog(%d) = %q, expected %q", maxLength, got, expected)
+		}
+	}
+}
+
+func TestGenerateManifest_EscapesNewlines(t *testing.T) {
+	manifest := map[string]interface{}{
+		"appname":   "demo",
+		"changelog": "- a\n- b",
+	}
+
+	content := GenerateManifest(manifest, parser.Variables{}, parser.I18nConfig{})
+	if !strings.Contains(content, `changelog       = - a\n- b`) {
+		t.Errorf("Expected escaped changelog line, got:\n%s", content)
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{"1.2.3-beta.1+g1a2b3c4", "1.2.3-beta.1+g1a2b3c4", true},
+		{"latest", "", false},
+		{"1.2.", "", false},
+	}
+
+	for _, tt := range tests {
+		result, err := NormalizeVersion(tt.input)
+		if (err == nil) != tt.valid || result != tt.expected {
+			t.Errorf("NormalizeVersion(%q) = %q, %v; expected %q, valid=%v", tt.input, result, err, tt.expected, tt.valid)
+		}
+	}
+
+	if got := AppendBuildMetadata("1.0.0", "3", "gabc1234"); got != "1.0.0+3.gabc1234" {
+		t.Errorf("AppendBuildMetadata = %q", got)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"v2.0.0", "10.0.0", -1},
+		{"1.0.0-beta.1", "1.0.0", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-alpha", "1.0.0-1", 1},
+		{"1.0.0+3.gabc", "1.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		result, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("CompareVersions(%q, %q) failed: %v", tt.a, tt.b, err)
+		}
+		if result != tt.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestReplaceVariablesExtra(t *testing.T) {
+	vars := parser.Variables{
+		ServiceName: "app",
+		Extra:       map[string]string{"REGISTRY": "ghcr.io", "SERVICE_NAME": "ignored"},
+	}
+
+	result := ReplaceVariables("${REGISTRY}/${SERVICE_NAME} ${UNKNOWN}", vars)
+	if result != "ghcr.io/app ${UNKNOWN}" {
+		t.Errorf("ReplaceVariables = %q", result)
+	}
+}
+
+func TestReplaceVariablesNested(t *testing.T) {
+	vars := parser.Variables{
+		ServiceName: "${A}",
+		Extra:       map[string]string{"A": "${B}", "B": "${SERVICE_NAME}", "C": "c"},
+	}
+
+	// Replaced values are not expanded again, whatever the map order
+	for i := 0; i < 20; i++ {
+		result := ReplaceVariables("${SERVICE_NAME} ${A} ${B} ${C}", vars)
+		if result != "${A} ${B} ${SERVICE_NAME} c" {
+			t.Fatalf("ReplaceVariables = %q", result)
+		}
+	}
+}
+
+func TestWizardSecretsScript(t *testing.T) {
+	wizard := `[{"stepTitle":"DB","items":[
+		{"type":"text","field":"wizard_user"},
+		{"type":"password","field":"wizard_db_password","secret":true}
+	]}]`
+
+	secrets, err := WizardSecrets(wizard)
+	if err != nil {
+		t.Fatalf("WizardSecrets failed: %v", err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "db_password" || secrets[0].Field != "wizard_db_password" {
+		t.Fatalf("WizardSecrets = %v", secrets)
+	}
+
+	script := InsertScriptFragment(LifecycleScripts["install_callback"], GenerateSecretsScript(secrets))
+	if !strings.HasPrefix(script, "#!/bin/bash\n") || !strings.HasSuffix(script, "\nexit 0\n") {
+		t.Errorf("fragment not inserted before the script body:\n%s", script)
+	}
+	if !strings.Contains(script, `(umask 077 && printf '%s' "${wizard_db_password}" > "$SECRETS_DIR/db_password")`) {
+		t.Errorf("secret not written:\n%s", script)
+	}
+	if !strings.Contains(script, `chmod 600 "$SECRETS_DIR/db_password"`) {
+		t.Errorf("secret file must only be readable by its owner:\n%s", script)
+	}
+}
+
+func TestStripWizardSecrets(t *testing.T) {
+	wizard := `[{"stepTitle":"DB","items":[
+		{"type":"text","field":"wizard_port","initValue":5432},
+		{"type":"password","field":"wizard_db_password","secret":true}
+	]}]`
+
+	result, ok, err := StripWizardSecrets(wizard)
+	if err != nil || !ok {
+		t.Fatalf("StripWizardSecrets = %v, %v", ok, err)
+	}
+	if strings.Contains(result, "secret") {
+		t.Errorf("secret key not removed:\n%s", result)
+	}
+	for _, want := range []string{`"field": "wizard_db_password"`, `"initValue": 5432`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in:\n%s", want, result)
+		}
+	}
+
+	plain := `[{"stepTitle":"DB","items":[{"type":"text","field":"wizard_user"}]}]`
+	if result, ok, err := StripWizardSecrets(plain); err != nil || ok || result != plain {
+		t.Errorf("a wizard without secrets must be kept verbatim, got %q, %v, %v", result, ok, err)
+	}
+	if _, _, err := StripWizardSecrets(`[`); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestInsertScriptFragment(t *testing.T) {
+	section := MarkSection("pre hook", "echo pre\n")
+	if section != "# >>> fpk-compose-builder: pre hook\necho pre\n# <<< fpk-compose-builder: pre hook\n" {
+		t.Errorf("MarkSection = %q", section)
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{"#!/bin/bash\n# comment\n\nexit 0\n", "#!/bin/bash\n# comment\n\n" + section + "\nexit 0\n"},
+		{"#!/bin/sh\necho body\n", "#!/bin/sh\n\n" + section + "\necho body\n"},
+		{"echo body", "\n" + section + "\necho body"},
+	}
+	for _, tt := range tests {
+		if result := InsertScriptFragment(tt.script, section); result != tt.expected {
+			t.Errorf("InsertScriptFragment(%q) = %q, expected %q", tt.script, result, tt.expected)
+		}
+	}
+}
+
+func TestWizardItemCheckValue(t *testing.T) {
+	wizard := `[{"stepTitle":"Setup","items":[
+		{"type":"tips","helpText":"Welcome"},
+		{"type":"text","field":"wizard_user","rules":[{"required":true,"min":3,"max":10}]},
+		{"type":"number","field":"wizard_port","initValue":8080,"rules":[{"min":1024,"max":65535}]},
+		{"type":"text","field":"wizard_mail","rules":[{"pattern":"^[^@]+@[^@]+$","message":"must be an e-mail address"}]}
+	]}]`
+
+	items, err := WizardItems(wizard)
+	if err != nil {
+		t.Fatalf("WizardItems failed: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("expected 3 fields, got %d", len(items))
+	}
+	user, port, mail := items[0], items[1], items[2]
+	if !user.Required() || port.Required() {
+		t.Error("only wizard_user should be required")
+	}
+
+	tests := []struct {
+		item     WizardItem
+		value    string
+		expected []string
+	}{
+		{user, "alice", nil},
+		{user, "", []string{"wizard_user: is required"}},
+		{user, "al", []string{"wizard_user: must be at least 3 characters"}},
+		{port, "80", []string{"wizard_port: must be at least 1024"}},
+		{port, "http", []string{`wizard_port: "http" is not a number`}},
+		{mail, "", nil},
+		{mail, "nope", []string{"wizard_mail: must be an e-mail address"}},
+	}
+	for _, tt := range tests {
+		if problems := tt.item.CheckValue(tt.value); !reflect.DeepEqual(problems, tt.expected) {
+			t.Errorf("CheckValue(%s, %q) = %v, expected %v", tt.item.Field, tt.value, problems, tt.expected)
+		}
+	}
+}
+
+func TestBackupRestoreScripts(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	pkgvar := t.TempDir()
+	env := append(os.Environ(), "TRIM_PKGVAR="+pkgvar, "TRIM_APPDEST="+t.TempDir())
+	dataFile := filepath.Join(pkgvar, "data", "db")
+	paths := []string{"${TRIM_PKGVAR}/data", "${TRIM_PKGVAR}/missing dir"}
+
+	run := func(script string) error {
+		cmd := exec.Command(sh, "-c", script)
+		cmd.Env = env
+		return cmd.Run()
+	}
+	write := func(content string) {
+		if err := os.MkdirAll(filepath.Dir(dataFile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dataFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() string {
+		content, _ := os.ReadFile(dataFile)
+		return string(content)
+	}
+
+	write("v1")
+	if err := run(GenerateBackupScript(paths, "demo") + "exit 0\n"); err != nil {
+		t.Fatalf("backup failed: %v", err)
+	}
+
+	// A successful upgrade_callback keeps the migrated data
+	callback := GenerateRestoreScript(paths) + GenerateExitScript(false, true)
+	write("v2")
+	if err := run(callback + "exit 0\n"); err != nil {
+		t.Fatalf("upgrade_callback failed: %v", err)
+	}
+	if read() != "v2" {
+		t.Errorf("data = %q after a successful upgrade, expected v2", read())
+	}
+
+	// Any failure restores the backup and keeps the exit code
+	if err := run(callback + "exit 3\n"); err == nil {
+		t.Fatal("expected the failing upgrade_callback to fail")
+	}
+	if read() != "v1" {
+		t.Errorf("data = %q after a failed upgrade, expected the backup v1", read())
+	}
+}
+
+func TestLifecycleScriptsProjectName(t *testing.T) {
+	// Every compose call targets the project the volume cleanup filters on
+	sections := map[string]string{
+		"backup":  GenerateBackupScript([]string{"${TRIM_PKGVAR}"}, "my-app"),
+		"migrate": GenerateMigrateScript("migrate", "my-app"),
+		"stop":    GenerateStopScript("my-app"),
+	}
+	for name, section := range sections {
+		calls := strings.Count(section, "docker compose ")
+		if calls == 0 || strings.Count(section, `docker compose -p "my-app" -f "$FILE_PATH"`) != calls {
+			t.Errorf("%s section runs compose without the project:\n%s", name, section)
+		}
+	}
+
+	cleanup := GenerateCleanupScript("wizard_remove_data", "my-app", nil)
+	if !strings.Contains(cleanup, `--filter "label=com.docker.compose.project=my-app"`) {
+		t.Errorf("cleanup section does not filter on the project:\n%s", cleanup)
+	}
+}
+
+func TestExitScriptPostHook(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	script := GeneratePostHookScript(`echo "post $1"`) + GenerateExitScript(true, false)
+
+	out, err := exec.Command(sh, "-c", script+"echo body\n", "main", "start").Output()
+	if err != nil || string(out) != "body\npost start\n" {
+		t.Errorf("output = %q (%v), expected the post hook after the body", out, err)
+	}
+	out, _ = exec.Command(sh, "-c", script+"exit 1\n", "main", "start").Output()
+	if strings.Contains(string(out), "post") {
+		t.Errorf("post hook ran after a failure: %q", out)
+	}
+}
+
+func TestUIConfigEntryValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry UIConfigEntry
+		err   string
+	}{
+		{"port entry", NewPortUIEntry("App", "icon", "https", "8443", "/"), ""},
+		{"path entry", NewPathUIEntry("App", "icon", "/app/"), ""},
+		{"iframe with port", NewIframeUIEntry("App", "icon", "http", "8080", "/"), ""},
+		{"iframe through portal", NewIframeUIEntry("App", "icon", "http", "", "/"), ""},
+		{"unknown type", UIConfigEntry{Type: "window", URL: "/"}, "unsupported type"},
+		{"relative url", NewPortUIEntry("App", "icon", "http", "8080", "app"), "must start with /"},
+		{"port out of range", NewPortUIEntry("App", "icon", "http", "70000", "/"), "invalid port"},
+		{"port not a number", NewPortUIEntry("App", "icon", "http", "${PORT}", "/"), "invalid port"},
+		{"unknown protocol", NewPortUIEntry("App", "icon", "ftp", "21", "/"), "invalid protocol"},
+		{"protocol without port", UIConfigEntry{Type: UITypeURL, Protocol: "http", URL: "/app/"}, "requires a port"},
+		{"path entry at portal root", NewPathUIEntry("App", "icon", "/"), "path other than /"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("Validate failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Validate error = %v, expected %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewUIConfigEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    parser.UIEntry
+		expected UIConfigEntry
+		err      string
+	}{
+		{
+			name:     "port",
+			entry:    parser.UIEntry{Title: "Web", Type: parser.UIModePort, Protocol: "http", Port: "8080", Path: "/"},
+			expected: UIConfigEntry{Title: "Web", Icon: "icon", Type: UITypeURL, Protocol: "http", Port: "8080", URL: "/", AllUsers: true},
+		},
+		{
+			name:     "path",
+			entry:    parser.UIEntry{Title: "Web", Type: parser.UIModePath, Path: "/web/"},
+			expected: UIConfigEntry{Title: "Web", Icon: "icon", Type: UITypeURL, URL: "/web/", AllUsers: true},
+		},
+		{
+			name:     "iframe",
+			entry:    parser.UIEntry{Title: "Web", Type: parser.UIModeIframe, Protocol: "https", Port: "8443", Path: "/"},
+			expected: UIConfigEntry{Title: "Web", Icon: "icon", Type: UITypeIframe, Protocol: "https", Port: "8443", URL: "/", AllUsers: true},
+		},
+		{
+			name:  "port entry without port",
+			entry: parser.UIEntry{Service: "worker", Type: parser.UIModePort},
+			err:   "service worker publishes none",
+		},
+		{
+			name:  "path entry with protocol",
+			entry: parser.UIEntry{Type: parser.UIModePath, Protocol: "https", Path: "/web/"},
+			err:   `cannot set protocol "https"`,
+		},
+		{
+			name:  "path entry with port",
+			entry: parser.UIEntry{Type: parser.UIModePath, Port: "8080", Path: "/web/"},
+			err:   `cannot set port "8080"`,
+		},
+		{
+			name:  "unknown mode",
+			entry: parser.UIEntry{Type: "window"},
+			err:   "unsupported UI mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewUIConfigEntry(tt.entry, "icon")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("NewUIConfigEntry error = %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewUIConfigEntry failed: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("NewUIConfigEntry = %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckBasePathEnv(t *testing.T) {
+	tests := []struct {
+		env     []string
+		matches bool
+	}{
+		{[]string{"BASE_PATH=/my-app"}, true},
+		{[]string{"BASE_PATH=/my-app/"}, true},
+		{[]string{"TZ=UTC", "ROOT_URL=https://nas.local/my-app/"}, true},
+		{[]string{"BASE_PATH=/other"}, false},
+		{[]string{"BASE_PATH"}, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		err := CheckBasePathEnv(parser.Service{Environment: tt.env}, "/my-app/")
+		if (err == nil) != tt.matches {
+			t.Errorf("CheckBasePathEnv(%v) = %v, expected match %v", tt.env, err, tt.matches)
+		}
+	}
+}
+
+func TestGenerateUIConfigErrors(t *testing.T) {
+	services := map[string]parser.Service{
+		"web":   {Ports: []string{"8080:80"}},
+		"admin": {Ports: []string{"8443:443"}},
+	}
+	port := func(name, service string) parser.UIEntry {
+		return parser.UIEntry{Name: name, Service: service, Title: name, Type: parser.UIModePort, Protocol: "http", Port: "8080", Path: "/"}
+	}
+
+	tests := []struct {
+		name    string
+		entries []parser.UIEntry
+		err     string
+	}{
+		{"valid", []parser.UIEntry{port("web", "web"), port("admin", "admin")}, ""},
+		{"unknown service", []parser.UIEntry{port("web", "web"), port("api", "api")}, "service api does not exist"},
+		{"duplicate name", []parser.UIEntry{port("web", "web"), port("web", "admin")}, "UI entry web is defined more than once"},
+		{"name with a path", []parser.UIEntry{port("web", "web"), port("../admin", "admin")}, `invalid UI entry name "../admin"`},
+		{"name with a dot", []parser.UIEntry{port("web.v2", "web")}, `invalid UI entry name "web.v2"`},
+		{"path entry with protocol", []parser.UIEntry{{Name: "web", Service: "web", Type: parser.UIModePath, Protocol: "http", Path: "/web/"}}, "cannot set protocol"},
+		{
+			"base path not configured",
+			[]parser.UIEntry{{Name: "web", Service: "web", Type: parser.UIModePath, Path: "/web/", CheckBasePath: true}},
+			"matches base path /web/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateUIConfig(tt.entries, services, parser.Variables{}, "demo", parser.I18nConfig{})
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("GenerateUIConfig failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("GenerateUIConfig error = %v, expected %q", err, tt.err)
+			}
+		})
+	}
+}
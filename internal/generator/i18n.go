@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// localizableWizardKeys are the wizard JSON keys whose value may be a {locale: text} map
+var localizableWizardKeys = map[string]bool{
+	"stepTitle":   true,
+	"label":       true,
+	"helpText":    true,
+	"placeholder": true,
+	"message":     true,
+}
+
+// LocalizedName returns the manifest key, JSON field or file name for a locale
+// e.g., "display_name" -> "display_name_en", "wizard/install" -> "wizard/install_en"
+func LocalizedName(name, locale string) string {
+	return name + "_" + locale
+}
+
+// TargetLocales returns the non-default locales emitted for a set of translations
+// Uses the configured locales when set, the locales of the translations otherwise
+func TargetLocales(values map[string]string, i18n parser.I18nConfig) []string {
+	locales := i18n.Locales
+	if len(locales) == 0 {
+		locales = parser.SortedLocales(values)
+	}
+
+	defaultLocale := i18n.Default()
+	var result []string
+	for _, locale := range locales {
+		if locale != defaultLocale {
+			result = append(result, locale)
+		}
+	}
+	return result
+}
+
+// IsWizardFile reports whether an x-fnpack file path is a wizard definition
+func IsWizardFile(path string) bool {
+	return strings.HasPrefix(path, "wizard/")
+}
+
+// LocalizeWizard renders a wizard JSON with localized strings resolved for one locale
+// Returns ok=false when the wizard has no localized strings (content is kept verbatim then)
+func LocalizeWizard(content, locale string, i18n parser.I18nConfig) (result string, ok bool, err error) {
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return "", false, fmt.Errorf("failed to parse wizard JSON: %w", err)
+	}
+
+	found := false
+	data = walkWizard(data, func(values map[string]string) string {
+		found = true
+		return parser.ResolveLocale(values, locale, i18n.Default())
+	})
+	if !found {
+		return content, false, nil
+	}
+
+	result, err = encodeWizard(data)
+	if err != nil {
+		return "", false, err
+	}
+	return result, true, nil
+}
+
+// encodeWizard encodes a rewritten wizard JSON
+func encodeWizard(data interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// walkWizard replaces localized wizard strings using resolve
+func walkWizard(node interface{}, resolve func(map[string]string) string) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if localizableWizardKeys[key] {
+				if values, ok := parser.LocalizedValues(value); ok {
+					v[key] = resolve(values)
+					continue
+				}
+			}
+			v[key] = walkWizard(value, resolve)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = walkWizard(value, resolve)
+		}
+	}
+	return node
+}
+
+// WizardTranslations returns the localized strings of a wizard JSON
+// Keys are JSON paths such as "[0].items[1].label"
+func WizardTranslations(content string) (map[string]map[string]string, error) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse wizard JSON: %w", err)
+	}
+
+	result := make(map[string]map[string]string)
+	var walk func(node interface{}, path string)
+	walk = func(node interface{}, path string) {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if localizableWizardKeys[key] {
+					if values, ok := parser.LocalizedValues(value); ok {
+						result[path+"."+key] = values
+						continue
+					}
+				}
+				walk(value, path+"."+key)
+			}
+		case []interface{}:
+			for i, value := range v {
+				walk(value, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(data, "")
+
+	return result, nil
+}
+
+// MissingTranslations reports every localized string lacking a required locale
+// Required locales are x-fnpack.i18n.locales, or all locales used anywhere if not set
+func MissingTranslations(compose *parser.ComposeFile, entries []parser.UIEntry) ([]string, error) {
+	translations := make(map[string]map[string]string)
+
+	for key, value := range compose.XFnpack.Manifest {
+		if values, ok := parser.LocalizedValues(value); ok {
+			translations["manifest."+key] = values
+		}
+	}
+
+	for path, content := range compose.XFnpack.Files {
+		if !IsWizardFile(path) {
+			continue
+		}
+		wizardTranslations, err := WizardTranslations(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for location, values := range wizardTranslations {
+			translations[path+location] = values
+		}
+	}
+
+	for _, entry := range entries {
+		if len(entry.Titles) > 0 {
+			translations["ui."+entry.Name+".title"] = entry.Titles
+		}
+	}
+
+	required := compose.XFnpack.I18n.Locales
+	if len(required) == 0 {
+		used := make(map[string]string)
+		for _, values := range translations {
+			for locale := range values {
+				used[locale] = ""
+			}
+		}
+		required = parser.SortedLocales(used)
+	}
+
+	var missing []string
+	for location, values := range translations {
+		for _, locale := range required {
+			if _, ok := values[locale]; !ok {
+				missing = append(missing, fmt.Sprintf("%s: missing translation for locale %q", location, locale))
+			}
+		}
+	}
+	sort.Strings(missing)
+
+	return missing, nil
+}
@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// ScriptMarker names the generator in the markers of generated script sections
+const ScriptMarker = "fpk-compose-builder"
+
+// BackupDir is the directory of the upgrade backup, excluded from the archive
+const BackupDir = ".fpk-backup"
+
+// BackupFile is the archive of the data directories written by upgrade_init
+const BackupFile = "${TRIM_PKGVAR}/" + BackupDir + "/upgrade.tar.gz"
+
+// composeFileVar declares the compose file path in the generated sections
+const composeFileVar = `FILE_PATH="${TRIM_APPDEST}/docker/docker-compose.yaml"` + "\n"
+
+// composeCommand returns the docker compose invocation of the generated sections
+// The project is passed explicitly, Compose would otherwise name it after the
+// directory of the compose file ("docker")
+func composeCommand(project string) string {
+	return `docker compose -p ` + shellQuote(project) + ` -f "$FILE_PATH"`
+}
+
+// MarkSection wraps a generated script section in begin and end markers
+func MarkSection(name, content string) string {
+	return fmt.Sprintf("# >>> %s: %s\n%s# <<< %s: %s\n", ScriptMarker, name, content, ScriptMarker, name)
+}
+
+// InsertScriptFragment inserts a fragment into a script after its shebang and
+// leading comments, so it runs before the script body
+func InsertScriptFragment(script, fragment string) string {
+	lines := strings.SplitAfter(script, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], "#") {
+		i++
+	}
+	head := strings.Join(lines[:i], "")
+	if head != "" && !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	body := strings.Join(lines[i:], "")
+	if body != "" && !strings.HasPrefix(body, "\n") {
+		body = "\n" + body
+	}
+	return head + "\n" + fragment + body
+}
+
+// GenerateBackupScript generates the upgrade_init section archiving the data
+// directories, with the containers stopped so that the archive is consistent
+// A failed backup starts the containers again and aborts the upgrade
+func GenerateBackupScript(paths []string, project string) string {
+	var sb strings.Builder
+	sb.WriteString(backupVars(paths))
+	sb.WriteString(composeFileVar)
+	compose := composeCommand(project)
+	sb.WriteString(`fpk_backup() {
+    set --
+    while IFS= read -r backup_path; do
+        if [ -n "$backup_path" ] && [ -e "$backup_path" ]; then
+            set -- "$@" "${backup_path#/}"
+        fi
+    done <<FPK_BACKUP_PATHS
+$BACKUP_PATHS
+FPK_BACKUP_PATHS
+    mkdir -p "$(dirname "$BACKUP_FILE")" && rm -f "$BACKUP_FILE" || return 1
+    [ $# -eq 0 ] || tar -czf "$BACKUP_FILE" --exclude="` + BackupDir + `" -C / "$@"
+}
+if [ -f "$FILE_PATH" ]; then
+    ` + compose + ` stop || true
+fi
+if ! fpk_backup; then
+    echo "Failed to back up the data directories" >&2
+    if [ -f "$FILE_PATH" ]; then
+        ` + compose + ` start || true
+    fi
+    exit 1
+fi
+`)
+	return sb.String()
+}
+
+// GenerateRestoreScript generates the upgrade_callback section defining
+// restore_backup, which replaces the data directories with the upgrade backup
+// The exit section (see GenerateExitScript) calls it when the script fails
+func GenerateRestoreScript(paths []string) string {
+	var sb strings.Builder
+	sb.WriteString(backupVars(paths))
+	sb.WriteString(`restore_backup() {
+    [ -f "$BACKUP_FILE" ] || return 1
+    echo "Restoring the data directories from $BACKUP_FILE" >&2
+    while IFS= read -r backup_path; do
+        if [ -n "$backup_path" ] && [ -d "$backup_path" ]; then
+            find "$backup_path" -mindepth 1 -maxdepth 1 ! -name "` + BackupDir + `" -exec rm -rf {} +
+        fi
+    done <<FPK_BACKUP_PATHS
+$BACKUP_PATHS
+FPK_BACKUP_PATHS
+    tar -xzf "$BACKUP_FILE" -C /
+}
+`)
+	return sb.String()
+}
+
+// GenerateExitScript generates the section installing the EXIT trap of a script
+// On success the post hook runs (postHook), on failure of the script or the
+// hook the backup is restored (restore)
+func GenerateExitScript(postHook, restore bool) string {
+	var sb strings.Builder
+	sb.WriteString(`fpk_on_exit() {
+    fpk_status=$?
+    trap - EXIT
+`)
+	if postHook {
+		sb.WriteString(`    if [ "$fpk_status" -eq 0 ]; then
+        fpk_post_hook "$@"
+        fpk_status=$?
+    fi
+`)
+	}
+	if restore {
+		sb.WriteString(`    if [ "$fpk_status" -ne 0 ]; then
+        restore_backup
+    fi
+`)
+	}
+	sb.WriteString(`    exit "$fpk_status"
+}
+trap 'fpk_on_exit "$@"' EXIT
+`)
+	return sb.String()
+}
+
+// GenerateMigrateScript generates the upgrade_callback section running the
+// one-shot migrate service, a failed migration fails the script
+func GenerateMigrateScript(service, project string) string {
+	var sb strings.Builder
+	sb.WriteString(composeFileVar)
+	fmt.Fprintf(&sb, "if ! %s --profile %s run --rm %s; then\n", composeCommand(project), parser.MigrateProfile, service)
+	fmt.Fprintf(&sb, "    echo \"Migration (service %s) failed\" >&2\n", service)
+	sb.WriteString("    exit 1\nfi\n")
+	return sb.String()
+}
+
+// GenerateStopScript generates the uninstall_init section stopping the containers
+func GenerateStopScript(project string) string {
+	return composeFileVar + `if [ -f "$FILE_PATH" ]; then
+    ` + composeCommand(project) + ` stop || true
+fi
+`
+}
+
+// GenerateCleanupScript generates the uninstall_callback section removing the
+// images and the named volumes of the compose project when the wizard field is checked
+func GenerateCleanupScript(field, project string, images []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "case \"${%s}\" in\ntrue|1|yes)\n", field)
+	if len(images) > 0 {
+		fmt.Fprintf(&sb, "    for image in %s; do\n", shellWords(images))
+		sb.WriteString("        docker image rm \"$image\" >/dev/null 2>&1 || true\n")
+		sb.WriteString("    done\n")
+	}
+	fmt.Fprintf(&sb, "    docker volume ls -q --filter %s | xargs -r docker volume rm || true\n", shellQuote("label=com.docker.compose.project="+project))
+	sb.WriteString("    ;;\nesac\n")
+	return sb.String()
+}
+
+// GeneratePostHookScript generates the section defining the post hook, which
+// the exit section (see GenerateExitScript) runs after the script body exited
+// successfully; the hook gets the script arguments, a failing hook fails the script
+func GeneratePostHookScript(hook string) string {
+	if !strings.HasSuffix(hook, "\n") {
+		hook += "\n"
+	}
+	return "fpk_post_hook() {\n" + hook + "}\n"
+}
+
+// backupVars declares the backup file and the newline-separated backup directories
+func backupVars(paths []string) string {
+	return fmt.Sprintf("BACKUP_FILE=\"%s\"\nBACKUP_PATHS=%s\n", BackupFile, shellQuote(strings.Join(paths, "\n")))
+}
+
+// shellWords double-quotes each word, keeping variable references expandable
+func shellWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote double-quotes a word, keeping variable references expandable
+func shellQuote(word string) string {
+	word = strings.ReplaceAll(word, `\`, `\\`)
+	word = strings.ReplaceAll(word, `"`, `\"`)
+	word = strings.ReplaceAll(word, "`", "\\`")
+	return `"` + word + `"`
+}
@@ -42,10 +42,15 @@ var ManifestFieldOrder = []string{
 
 // GenerateManifest generates manifest content in key=value format from YAML object
 // It applies default values for missing fields and replaces variables
-func GenerateManifest(manifest map[string]interface{}, vars parser.Variables) string {
+// Localized {locale: text} values write the default locale to the key itself and
+// the other locales to "<key>_<locale>" lines right after it
+func GenerateManifest(manifest map[string]interface{}, vars parser.Variables, i18n parser.I18nConfig) string {
 	// Create a working copy with defaults applied
 	result := make(map[string]string)
 
+	// Localized lines to add after their base key
+	localizedLines := make(map[string][]string)
+
 	// Apply defaults first
 	for key, defaultValue := range ManifestDefaults {
 		result[key] = defaultValue
@@ -79,6 +84,16 @@ func GenerateManifest(manifest map[string]interface{}, vars parser.Variables) st
 	// Override with provided manifest values
 	if manifest != nil {
 		for key, value := range manifest {
+			if values, ok := parser.LocalizedValues(value); ok {
+				defaultLocale := i18n.Default()
+				result[key] = ReplaceVariables(parser.ResolveLocale(values, defaultLocale, defaultLocale), vars)
+				for _, locale := range TargetLocales(values, i18n) {
+					text := ReplaceVariables(parser.ResolveLocale(values, locale, defaultLocale), vars)
+					localizedLines[key] = append(localizedLines[key], formatManifestLine(LocalizedName(key, locale), text))
+				}
+				continue
+			}
+
 			strValue := formatManifestValue(value)
 			// Replace variables in the value
 			strValue = ReplaceVariables(strValue, vars)
@@ -104,6 +119,7 @@ func GenerateManifest(manifest map[string]interface{}, vars parser.Variables) st
 	for _, key := range ManifestFieldOrder {
 		if value, ok := result[key]; ok && value != "" {
 			lines = append(lines, formatManifestLine(key, value))
+			lines = append(lines, localizedLines[key]...)
 			addedKeys[key] = true
 		}
 	}
@@ -119,6 +135,7 @@ func GenerateManifest(manifest map[string]interface{}, vars parser.Variables) st
 
 	for _, key := range remainingKeys {
 		lines = append(lines, formatManifestLine(key, result[key]))
+		lines = append(lines, localizedLines[key]...)
 	}
 
 	return strings.Join(lines, "\n") + "\n"
@@ -143,7 +160,11 @@ func formatManifestValue(value interface{}) string {
 
 // formatManifestLine formats a key-value pair for the manifest file
 // Uses padding to align values for readability
+// Newlines are escaped as "\n" since every entry must stay on one line
 func formatManifestLine(key, value string) string {
+	value = strings.ReplaceAll(value, "\r", "")
+	value = strings.ReplaceAll(value, "\n", `\n`)
+
 	// Pad key to 16 characters for alignment (like the reference manifest)
 	if len(key) < 16 {
 		return fmt.Sprintf("%-16s= %s", key, value)
@@ -1,29 +1,39 @@
 package generator
 
 import (
-	"strings"
+	"regexp"
 
 	"fpk-compose-builder/internal/parser"
 )
 
+// placeholderPattern matches a ${KEY} template variable
+var placeholderPattern = regexp.MustCompile(`\$\{([^{}]+)\}`)
+
 // ReplaceVariables replaces template variables in the given content
 // Supported variables:
 //   - ${SERVICE_NAME}: First service name
 //   - ${CONTAINER_NAME}: First service container_name (or service name if not specified)
 //   - ${FIRST_PORT}: First port of the first service (host port)
+//   - ${KEY}: User-defined variables from vars.Extra (built-in variables take precedence)
+//
+// Content is replaced in a single pass, placeholders inside replaced values are kept
 func ReplaceVariables(content string, vars parser.Variables) string {
-	replacements := map[string]string{
-		"${SERVICE_NAME}":   vars.ServiceName,
-		"${CONTAINER_NAME}": vars.ContainerName,
-		"${FIRST_PORT}":     vars.FirstPort,
-	}
-
-	result := content
-	for placeholder, value := range replacements {
-		result = strings.ReplaceAll(result, placeholder, value)
+	builtins := map[string]string{
+		"SERVICE_NAME":   vars.ServiceName,
+		"CONTAINER_NAME": vars.ContainerName,
+		"FIRST_PORT":     vars.FirstPort,
 	}
 
-	return result
+	return placeholderPattern.ReplaceAllStringFunc(content, func(placeholder string) string {
+		key := placeholder[2 : len(placeholder)-1]
+		if value, ok := builtins[key]; ok {
+			return value
+		}
+		if value, ok := vars.Extra[key]; ok {
+			return value
+		}
+		return placeholder
+	})
 }
 
 // ReplaceVariablesInMap replaces variables in all string values of a map
@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fpk-compose-builder/internal/parser"
+)
+
+// WizardCallbacks maps the wizard files to the lifecycle script run after them
+var WizardCallbacks = map[string]string{
+	"wizard/install":   "install_callback",
+	"wizard/config":    "config_callback",
+	"wizard/upgrade":   "upgrade_callback",
+	"wizard/uninstall": "uninstall_callback",
+}
+
+// WizardSecrets returns the fields of a wizard JSON marked "secret": true, in order of appearance
+func WizardSecrets(content string) ([]parser.Secret, error) {
+	var steps []struct {
+		Items []struct {
+			Field  string `json:"field"`
+			Secret bool   `json:"secret"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(content), &steps); err != nil {
+		return nil, fmt.Errorf("failed to parse wizard JSON: %w", err)
+	}
+
+	var secrets []parser.Secret
+	for _, step := range steps {
+		for _, item := range step.Items {
+			if item.Secret && item.Field != "" {
+				secrets = append(secrets, parser.Secret{Name: parser.SecretName(item.Field), Field: item.Field})
+			}
+		}
+	}
+	return secrets, nil
+}
+
+// StripWizardSecrets removes the builder-only "secret" key from the items of a wizard JSON
+// Returns ok=false when no item has the key (content is kept verbatim then)
+func StripWizardSecrets(content string) (result string, ok bool, err error) {
+	var steps []interface{}
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&steps); err != nil {
+		return "", false, fmt.Errorf("failed to parse wizard JSON: %w", err)
+	}
+
+	found := false
+	for _, step := range steps {
+		step, _ := step.(map[string]interface{})
+		items, _ := step["items"].([]interface{})
+		for _, item := range items {
+			if item, isMap := item.(map[string]interface{}); isMap {
+				if _, has := item["secret"]; has {
+					delete(item, "secret")
+					found = true
+				}
+			}
+		}
+	}
+	if !found {
+		return content, false, nil
+	}
+
+	result, err = encodeWizard(steps)
+	if err != nil {
+		return "", false, err
+	}
+	return result, true, nil
+}
+
+// GenerateSecretsScript generates the bash fragment writing the secret wizard
+// values to their files, fields the wizard did not set keep their file
+// The files are only readable by the user running the scripts, docker compose
+// bind-mounts them with these permissions, so the container must run as root
+// or as that user to read its secrets
+func GenerateSecretsScript(secrets []parser.Secret) string {
+	var sb strings.Builder
+	sb.WriteString("# Write the secret wizard values, docker compose mounts them as secrets\n")
+	fmt.Fprintf(&sb, "SECRETS_DIR=\"%s\"\n", parser.SecretsDir)
+	sb.WriteString("mkdir -p \"$SECRETS_DIR\" && chmod 700 \"$SECRETS_DIR\"\n")
+	for _, secret := range secrets {
+		fmt.Fprintf(&sb, "if [ -n \"${%s+x}\" ]; then\n", secret.Field)
+		fmt.Fprintf(&sb, "    (umask 077 && printf '%%s' \"${%s}\" > \"$SECRETS_DIR/%s\")\n", secret.Field, secret.Name)
+		fmt.Fprintf(&sb, "    chmod 600 \"$SECRETS_DIR/%s\"\n", secret.Name)
+		sb.WriteString("fi\n")
+	}
+	return sb.String()
+}
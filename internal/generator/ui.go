@@ -1,10 +1,38 @@
 package generator
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"fpk-compose-builder/internal/parser"
 )
 
+// uiEntryName matches the UI entry names allowed in entry keys and icon file names
+var uiEntryName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateUIEntryName checks that a UI entry name is safe to use as a file name
+func ValidateUIEntryName(name string) error {
+	if !uiEntryName.MatchString(name) {
+		return fmt.Errorf("invalid UI entry name %q (use letters, digits, _ and -)", name)
+	}
+	return nil
+}
+
+// UI config entry types understood by fnOS
+const (
+	// UITypeURL opens the entry in a new browser tab
+	UITypeURL = "url"
+
+	// UITypeIframe opens the entry inside an fnOS desktop window
+	UITypeIframe = "iframe"
+)
+
 // UIConfigEntry represents a single UI entry configuration
+// Port-based entries set protocol and port, path-based entries only set the
+// url path (reached through the NAS web portal), iframe entries embed either
 type UIConfigEntry struct {
 	Title    string `json:"title"`
 	Icon     string `json:"icon"`
@@ -13,6 +41,146 @@ type UIConfigEntry struct {
 	Port     string `json:"port,omitempty"`
 	URL      string `json:"url"`
 	AllUsers bool   `json:"allUsers"`
+
+	// Titles contains the non-default locale titles, written as "title_<locale>"
+	Titles map[string]string `json:"-"`
+}
+
+// MarshalJSON writes the entry fields followed by the localized titles
+func (e UIConfigEntry) MarshalJSON() ([]byte, error) {
+	type plainEntry UIConfigEntry
+	data, err := json.Marshal(plainEntry(e))
+	if err != nil || len(e.Titles) == 0 {
+		return data, err
+	}
+
+	var extra strings.Builder
+	for _, locale := range parser.SortedLocales(e.Titles) {
+		key, _ := json.Marshal(LocalizedName("title", locale))
+		value, err := json.Marshal(e.Titles[locale])
+		if err != nil {
+			return nil, err
+		}
+		extra.WriteString("," + string(key) + ":" + string(value))
+	}
+
+	// Insert before the closing brace of the object
+	return append(data[:len(data)-1], []byte(extra.String()+"}")...), nil
+}
+
+// NewPortUIEntry creates an entry opened at protocol://<nas>:<port><path>
+func NewPortUIEntry(title, icon, protocol, port, path string) UIConfigEntry {
+	return UIConfigEntry{
+		Title:    title,
+		Icon:     icon,
+		Type:     UITypeURL,
+		Protocol: protocol,
+		Port:     port,
+		URL:      path,
+		AllUsers: true,
+	}
+}
+
+// NewPathUIEntry creates an entry opened at <path> through the NAS web portal
+func NewPathUIEntry(title, icon, path string) UIConfigEntry {
+	return UIConfigEntry{
+		Title:    title,
+		Icon:     icon,
+		Type:     UITypeURL,
+		URL:      path,
+		AllUsers: true,
+	}
+}
+
+// NewIframeUIEntry creates an entry embedded in an fnOS desktop window
+// An empty port embeds the path through the NAS web portal
+func NewIframeUIEntry(title, icon, protocol, port, path string) UIConfigEntry {
+	entry := UIConfigEntry{
+		Title:    title,
+		Icon:     icon,
+		Type:     UITypeIframe,
+		URL:      path,
+		AllUsers: true,
+	}
+	if port != "" {
+		entry.Protocol = protocol
+		entry.Port = port
+	}
+	return entry
+}
+
+// Validate checks that the entry has the fields its mode requires
+func (e UIConfigEntry) Validate() error {
+	if e.Type != UITypeURL && e.Type != UITypeIframe {
+		return fmt.Errorf("unsupported type %q (expected %s or %s)", e.Type, UITypeURL, UITypeIframe)
+	}
+
+	if !strings.HasPrefix(e.URL, "/") {
+		return fmt.Errorf("url %q must start with /", e.URL)
+	}
+
+	if e.Port != "" {
+		if port, err := strconv.Atoi(e.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %q", e.Port)
+		}
+		if e.Protocol != "http" && e.Protocol != "https" {
+			return fmt.Errorf("invalid protocol %q (expected http or https)", e.Protocol)
+		}
+		return nil
+	}
+
+	// Without a port the entry is served by the NAS web portal,
+	// the portal root belongs to fnOS itself
+	if e.Protocol != "" {
+		return fmt.Errorf("protocol %q requires a port", e.Protocol)
+	}
+	if e.Type == UITypeURL && strings.Trim(e.URL, "/") == "" {
+		return fmt.Errorf("path-based entry needs a path other than /")
+	}
+
+	return nil
+}
+
+// NewUIConfigEntry creates the config entry for a UI entry according to its mode
+func NewUIConfigEntry(entry parser.UIEntry, icon string) (UIConfigEntry, error) {
+	switch entry.Type {
+	case parser.UIModePort, "":
+		if entry.Port == "" {
+			return UIConfigEntry{}, fmt.Errorf("port-based entry has no port (service %s publishes none)", entry.Service)
+		}
+		return NewPortUIEntry(entry.Title, icon, entry.Protocol, entry.Port, entry.Path), nil
+	case parser.UIModePath:
+		// The NAS web portal serves path entries, they have no protocol or port
+		if entry.Protocol != "" {
+			return UIConfigEntry{}, fmt.Errorf("path-based entry cannot set protocol %q", entry.Protocol)
+		}
+		if entry.Port != "" {
+			return UIConfigEntry{}, fmt.Errorf("path-based entry cannot set port %q", entry.Port)
+		}
+		return NewPathUIEntry(entry.Title, icon, entry.Path), nil
+	case parser.UIModeIframe:
+		return NewIframeUIEntry(entry.Title, icon, entry.Protocol, entry.Port, entry.Path), nil
+	default:
+		return UIConfigEntry{}, fmt.Errorf("unsupported UI mode %q (expected %s, %s or %s)",
+			entry.Type, parser.UIModePort, parser.UIModePath, parser.UIModeIframe)
+	}
+}
+
+// CheckBasePathEnv checks that the service has an environment variable matching path
+// Apps served under a sub-path usually need to know it (BASE_PATH=/my-app,
+// ROOT_URL=https://nas/my-app/, ...), so a value ending with the path counts as a match
+func CheckBasePathEnv(service parser.Service, path string) error {
+	want := strings.TrimSuffix(path, "/")
+	for _, env := range service.Environment {
+		_, value, ok := strings.Cut(env, "=")
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(strings.TrimSuffix(value, "/"), want) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no environment variable of the service matches base path %s", path)
 }
 
 // GenerateDefaultUIConfig generates the default app/ui/config JSON content
@@ -23,17 +191,77 @@ func GenerateDefaultUIConfig(vars parser.Variables, appname string) (string, err
 
 	config := map[string]interface{}{
 		".url": map[string]interface{}{
-			appEntry: UIConfigEntry{
-				Title:    appname,
-				Icon:     "images/icon-{0}.png",
-				Type:     "url",
-				Protocol: "http",
-				Port:     vars.FirstPort,
-				URL:      "/",
-				AllUsers: true,
-			},
+			appEntry: NewPortUIEntry(appname, "images/icon-{0}.png", "http", vars.FirstPort, "/"),
 		},
 	}
 
 	return marshalJSON(config)
 }
+
+// GenerateUIConfig generates app/ui/config JSON content with one entry per UI entry
+// The first entry uses "<appname>.Application" so it matches desktop_applaunchname,
+// the others use "<appname>.<entry name>"
+// Entries with their own icon reference images/<entry name>-{0}.png
+// Localized titles write the default locale to "title" and the others to "title_<locale>"
+func GenerateUIConfig(entries []parser.UIEntry, services map[string]parser.Service, vars parser.Variables, appname string, i18n parser.I18nConfig) (string, error) {
+	if len(entries) == 0 {
+		return GenerateDefaultUIConfig(vars, appname)
+	}
+
+	urlEntries := make(map[string]interface{})
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		if err := ValidateUIEntryName(entry.Name); err != nil {
+			return "", err
+		}
+		if _, ok := services[entry.Service]; !ok {
+			return "", fmt.Errorf("UI entry %s: service %s does not exist", entry.Name, entry.Service)
+		}
+		// Entry names are the keys and icon file names of the entries
+		if seen[entry.Name] {
+			return "", fmt.Errorf("UI entry %s is defined more than once (set a distinct name)", entry.Name)
+		}
+		seen[entry.Name] = true
+
+		key := appname + "." + entry.Name
+		if i == 0 {
+			key = appname + ".Application"
+		}
+
+		icon := "images/icon-{0}.png"
+		if entry.Icon != "" {
+			icon = "images/" + entry.Name + "-{0}.png"
+		}
+
+		entry.Title = ReplaceVariables(entry.Title, vars)
+		configEntry, err := NewUIConfigEntry(entry, icon)
+		if err != nil {
+			return "", fmt.Errorf("UI entry %s: %w", entry.Name, err)
+		}
+		if err := configEntry.Validate(); err != nil {
+			return "", fmt.Errorf("UI entry %s: %w", entry.Name, err)
+		}
+
+		if len(entry.Titles) > 0 {
+			configEntry.Titles = make(map[string]string)
+			for _, locale := range TargetLocales(entry.Titles, i18n) {
+				title := parser.ResolveLocale(entry.Titles, locale, i18n.Default())
+				configEntry.Titles[locale] = ReplaceVariables(title, vars)
+			}
+		}
+
+		if entry.CheckBasePath && configEntry.Port == "" {
+			if err := CheckBasePathEnv(services[entry.Service], entry.Path); err != nil {
+				return "", fmt.Errorf("UI entry %s: %w", entry.Name, err)
+			}
+		}
+
+		urlEntries[key] = configEntry
+	}
+
+	config := map[string]interface{}{
+		".url": urlEntries,
+	}
+
+	return marshalJSON(config)
+}
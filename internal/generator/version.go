@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// versionPattern matches dotted numeric versions with optional pre-release and build metadata
+// e.g., "1.2.3", "v1.2", "1.2.3-beta.1", "1.2.3+g1a2b3c4"
+var versionPattern = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// NormalizeVersion validates a version string and strips a leading "v"
+func NormalizeVersion(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	if !versionPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid version %q (expected e.g. 1.2.3 or v1.2.3-beta.1)", version)
+	}
+	return strings.TrimPrefix(version, "v"), nil
+}
+
+// IsVersion reports whether s is a valid version (see NormalizeVersion)
+func IsVersion(s string) bool {
+	_, err := NormalizeVersion(s)
+	return err == nil
+}
+
+// AppendBuildMetadata appends "+<parts joined by .>" to a version
+// Existing build metadata is extended rather than replaced
+func AppendBuildMetadata(version string, parts ...string) string {
+	if len(parts) == 0 {
+		return version
+	}
+	separator := "+"
+	if strings.Contains(version, "+") {
+		separator = "."
+	}
+	return version + separator + strings.Join(parts, ".")
+}
+
+// CompareVersions compares two versions, returning -1, 0 or 1
+// Versions are ordered by their dotted numeric parts (missing parts count as 0),
+// a pre-release sorts before its release, build metadata is ignored
+func CompareVersions(a, b string) (int, error) {
+	a, err := NormalizeVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	b, err = NormalizeVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	aMain, aPre := splitVersion(a)
+	bMain, bPre := splitVersion(b)
+
+	aParts := strings.Split(aMain, ".")
+	bParts := strings.Split(bMain, ".")
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		if c := compareNumeric(partAt(aParts, i), partAt(bParts, i)); c != 0 {
+			return c, nil
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0, nil
+	case aPre == "":
+		return 1, nil
+	case bPre == "":
+		return -1, nil
+	}
+
+	aIDs := strings.Split(aPre, ".")
+	bIDs := strings.Split(bPre, ".")
+	for i := 0; i < min(len(aIDs), len(bIDs)); i++ {
+		if c := comparePrerelease(aIDs[i], bIDs[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return compareInts(len(aIDs), len(bIDs)), nil
+}
+
+// splitVersion splits a normalized version into its numeric part and pre-release
+func splitVersion(version string) (main, prerelease string) {
+	version, _, _ = strings.Cut(version, "+")
+	main, prerelease, _ = strings.Cut(version, "-")
+	return main, prerelease
+}
+
+// partAt returns the i-th version part, "0" if missing
+func partAt(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+// compareNumeric compares two digit strings by numeric value
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return compareInts(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// comparePrerelease compares pre-release identifiers as in semver:
+// numeric identifiers numerically and lower than alphanumeric ones
+func comparePrerelease(a, b string) int {
+	aNum, bNum := isDigits(a), isDigits(b)
+	switch {
+	case aNum && bNum:
+		return compareNumeric(a, b)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareInts compares two integers, returning -1, 0 or 1
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
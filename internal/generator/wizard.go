@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// WizardFields returns the field names defined by a wizard JSON, in order of appearance
+func WizardFields(content string) ([]string, error) {
+	var steps []struct {
+		Items []struct {
+			Field string `json:"field"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(content), &steps); err != nil {
+		return nil, fmt.Errorf("failed to parse wizard JSON: %w", err)
+	}
+
+	var fields []string
+	for _, step := range steps {
+		for _, item := range step.Items {
+			if item.Field != "" {
+				fields = append(fields, item.Field)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// WizardItem is a wizard field with the rules its value must satisfy
+type WizardItem struct {
+	Field     string       `json:"field"`
+	Type      string       `json:"type"`
+	InitValue interface{}  `json:"initValue"`
+	Rules     []WizardRule `json:"rules"`
+}
+
+// WizardRule is a validation rule of a wizard field
+// min and max bound the value of number fields and the length of other fields
+type WizardRule struct {
+	Required bool        `json:"required"`
+	Min      *float64    `json:"min"`
+	Max      *float64    `json:"max"`
+	Pattern  string      `json:"pattern"`
+	Regex    string      `json:"regex"`
+	Message  interface{} `json:"message"`
+}
+
+// WizardItems returns the fields of a wizard JSON with their rules, in order of appearance
+func WizardItems(content string) ([]WizardItem, error) {
+	var steps []struct {
+		Items []WizardItem `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(content), &steps); err != nil {
+		return nil, fmt.Errorf("failed to parse wizard JSON: %w", err)
+	}
+
+	var items []WizardItem
+	for _, step := range steps {
+		for _, item := range step.Items {
+			if item.Field != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+// Required reports whether the field has a required rule
+func (item WizardItem) Required() bool {
+	for _, rule := range item.Rules {
+		if rule.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckValue checks a value against the rules of the field and returns the
+// violations, using the message of the rule when it has one
+// Empty values of optional fields are not checked
+func (item WizardItem) CheckValue(value string) []string {
+	if value == "" && !item.Required() {
+		return nil
+	}
+
+	var problems []string
+	for _, rule := range item.Rules {
+		if problem := item.checkRule(rule, value); problem != "" {
+			if message, ok := rule.Message.(string); ok && message != "" {
+				problem = message
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s", item.Field, problem))
+		}
+	}
+	return problems
+}
+
+// checkRule returns the violation of a single rule, empty if the value satisfies it
+func (item WizardItem) checkRule(rule WizardRule, value string) string {
+	if rule.Required && value == "" {
+		return "is required"
+	}
+
+	if rule.Min != nil || rule.Max != nil {
+		size, unit := float64(utf8.RuneCountInString(value)), " characters"
+		if item.Type == "number" {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Sprintf("%q is not a number", value)
+			}
+			size, unit = number, ""
+		}
+		if rule.Min != nil && size < *rule.Min {
+			return fmt.Sprintf("must be at least %v%s", *rule.Min, unit)
+		}
+		if rule.Max != nil && size > *rule.Max {
+			return fmt.Sprintf("must be at most %v%s", *rule.Max, unit)
+		}
+	}
+
+	for _, pattern := range []string{rule.Pattern, rule.Regex} {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Sprintf("invalid pattern %q: %v", pattern, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Sprintf("does not match %s", pattern)
+		}
+	}
+	return ""
+}
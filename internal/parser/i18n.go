@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultLocale is the default locale when x-fnpack.i18n.default_locale is not set
+const DefaultLocale = "zh"
+
+// Default returns the configured default locale or DefaultLocale
+func (c I18nConfig) Default() string {
+	if c.DefaultLocale != "" {
+		return c.DefaultLocale
+	}
+	return DefaultLocale
+}
+
+// LocalizedValues returns the translations of a {locale: text} value
+// Returns false for plain (non-map) values
+func LocalizedValues(value interface{}) (map[string]string, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+
+	values := make(map[string]string, len(m))
+	for locale, text := range m {
+		switch v := text.(type) {
+		case string:
+			values[locale] = v
+		default:
+			values[locale] = fmt.Sprintf("%v", v)
+		}
+	}
+	return values, true
+}
+
+// ResolveLocale returns the text for a locale from a set of translations
+// Falls back to the default locale, then to the first locale in alphabetical order
+func ResolveLocale(values map[string]string, locale, defaultLocale string) string {
+	if text, ok := values[locale]; ok {
+		return text
+	}
+	if text, ok := values[defaultLocale]; ok {
+		return text
+	}
+
+	locales := SortedLocales(values)
+	if len(locales) == 0 {
+		return ""
+	}
+	return values[locales[0]]
+}
+
+// SortedLocales returns the locales of a set of translations in alphabetical order
+func SortedLocales(values map[string]string) []string {
+	locales := make([]string, 0, len(values))
+	for locale := range values {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// GetLocalizedManifestValue gets a manifest value in the given locale with a default fallback
+// Plain values are returned as-is (see GetManifestValue)
+func GetLocalizedManifestValue(manifest map[string]interface{}, key, locale, defaultLocale, defaultValue string) string {
+	if values, ok := LocalizedValues(manifest[key]); ok {
+		return ResolveLocale(values, locale, defaultLocale)
+	}
+	return GetManifestValue(manifest, key, defaultValue)
+}
@@ -0,0 +1,997 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportFormats lists the app store formats Import converts from
+var ImportFormats = []string{"casaos", "umbrel", "portainer"}
+
+// umbrelGallery is where Umbrel hosts the icons of apps without an icon URL
+const umbrelGallery = "https://getumbrel.github.io/umbrel-apps-gallery"
+
+// importManifestOrder is the order of the manifest keys in converted compose files
+var importManifestOrder = []string{
+	"appname",
+	"version",
+	"display_name",
+	"desc",
+	"arch",
+	"maintainer",
+	"maintainer_url",
+	"distributor",
+	"distributor_url",
+}
+
+// importVariablePattern matches $$, ${NAME<modifier>} and $NAME references
+var importVariablePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)([:?+-][^}]*)?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ImportSource is an app in the format of another app store
+type ImportSource struct {
+	// Compose is the compose file (CasaOS, Umbrel and Portainer stack templates)
+	Compose []byte
+
+	// Metadata is the umbrel-app.yml file or the Portainer templates JSON
+	Metadata []byte
+
+	// Template selects the Portainer template by title or name
+	// Empty selects the only template of the file
+	Template string
+}
+
+// ImportResult is an app converted to a compose file with x-fnpack
+type ImportResult struct {
+	// Compose is the converted compose file
+	Compose *ComposeFile
+
+	// Content is the content of the converted compose file
+	Content []byte
+
+	// IconURL is the app icon to download, empty when the source has none
+	IconURL string
+
+	// Unmapped lists the source fields and variables without fnOS equivalent
+	Unmapped []string
+}
+
+// importWizardItem is an install wizard item of a converted app
+type importWizardItem struct {
+	Type      string         `json:"type"`
+	Field     string         `json:"field,omitempty"`
+	Label     interface{}    `json:"label,omitempty"`
+	HelpText  interface{}    `json:"helpText,omitempty"`
+	InitValue string         `json:"initValue,omitempty"`
+	Options   []importOption `json:"options,omitempty"`
+}
+
+// importOption is a choice of a select wizard item
+type importOption struct {
+	Label interface{} `json:"label"`
+	Value interface{} `json:"value"`
+}
+
+// importer collects the converted app while a source is translated
+type importer struct {
+	project  map[string]interface{}
+	manifest map[string]interface{}
+	ui       []UIEntry
+	wizard   []importWizardItem
+	vars     map[string]string
+	locales  map[string]bool
+	iconURL  string
+	unmapped []string
+}
+
+// Import converts an app of another app store (see ImportFormats) to a compose
+// file with x-fnpack manifest, install wizard and UI entries
+// Fields and variables that could not be mapped are listed in the result
+func Import(format string, src ImportSource) (*ImportResult, error) {
+	imp := &importer{
+		manifest: make(map[string]interface{}),
+		vars:     make(map[string]string),
+		locales:  make(map[string]bool),
+	}
+
+	var err error
+	switch format {
+	case "casaos":
+		err = imp.casaos(src)
+	case "umbrel":
+		err = imp.umbrel(src)
+	case "portainer":
+		err = imp.portainer(src)
+	default:
+		return nil, fmt.Errorf("unknown import format %q (supported: %s)", format, strings.Join(ImportFormats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return imp.result()
+}
+
+// casaos converts a CasaOS compose file, the app metadata is in x-casaos
+func (imp *importer) casaos(src ImportSource) error {
+	if err := imp.loadProject(src.Compose); err != nil {
+		return err
+	}
+	app, _ := imp.project["x-casaos"].(map[string]interface{})
+	if app == nil {
+		return fmt.Errorf("no x-casaos section found, not a CasaOS app")
+	}
+	delete(imp.project, "x-casaos")
+
+	name, _ := imp.project["name"].(string)
+	appname := importAppName(name)
+	dataDirs := []string{"/DATA/AppData/$AppID", "/DATA/AppData/${AppID}"}
+	if appname != "" {
+		imp.manifest["appname"] = appname
+		imp.vars["AppID"] = appname
+		dataDirs = append(dataDirs, "/DATA/AppData/"+name)
+	}
+	imp.vars["PUID"] = "${TRIM_UID}"
+	imp.vars["PGID"] = "${TRIM_GID}"
+
+	entry := UIEntry{}
+	for _, key := range sortedKeys(app) {
+		value := app[key]
+		switch key {
+		case "title":
+			imp.manifest["display_name"] = imp.localized(value)
+		case "tagline":
+			imp.manifest["desc"] = imp.localized(value)
+		case "description":
+			if app["tagline"] == nil {
+				imp.manifest["desc"] = imp.localized(value)
+			} else {
+				imp.unmap("x-casaos.description", "the manifest desc uses the tagline")
+			}
+		case "developer":
+			imp.manifest["maintainer"] = fmt.Sprint(value)
+		case "author":
+			imp.manifest["distributor"] = fmt.Sprint(value)
+		case "icon":
+			imp.iconURL = fmt.Sprint(value)
+		case "architectures":
+			imp.arch("x-casaos.architectures", value)
+		case "main":
+			entry.Service = fmt.Sprint(value)
+		case "port_map":
+			entry.Port = fmt.Sprint(value)
+		case "scheme":
+			entry.Protocol = fmt.Sprint(value)
+		case "index":
+			entry.Path = fmt.Sprint(value)
+		case "tips":
+			tips, _ := value.(map[string]interface{})
+			for _, name := range sortedKeys(tips) {
+				if name == "before_install" {
+					imp.tips(tips[name])
+				} else {
+					imp.unmap("x-casaos.tips."+name, "only before_install tips are shown in the wizard")
+				}
+			}
+		default:
+			imp.unmap("x-casaos."+key, "no fnOS equivalent")
+		}
+	}
+
+	services := imp.services()
+	for _, name := range sortedKeys(services) {
+		service, _ := services[name].(map[string]interface{})
+		extension, _ := service["x-casaos"].(map[string]interface{})
+		if extension == nil {
+			continue
+		}
+		delete(service, "x-casaos")
+
+		for _, key := range sortedKeys(extension) {
+			if key != "envs" {
+				imp.unmap(fmt.Sprintf("services.%s.x-casaos.%s", name, key), "descriptions are only kept for envs")
+				continue
+			}
+			envs, _ := extension[key].([]interface{})
+			for _, e := range envs {
+				env, _ := e.(map[string]interface{})
+				if env == nil || env["container"] == nil {
+					continue
+				}
+				imp.wizardEnv(name, service, fmt.Sprint(env["container"]), importWizardItem{
+					Label: imp.localized(env["description"]),
+				})
+			}
+		}
+	}
+
+	// App data lives in /DATA/AppData/<app> on CasaOS
+	imp.replaceStrings(func(s string) string {
+		for _, prefix := range dataDirs {
+			s = strings.ReplaceAll(s, prefix, "${TRIM_PKGVAR}")
+		}
+		return s
+	})
+	for _, name := range sortedKeys(services) {
+		service, _ := services[name].(map[string]interface{})
+		volumes, _ := service["volumes"].([]interface{})
+		for _, volume := range volumes {
+			if s, ok := volume.(string); ok && strings.HasPrefix(s, "/DATA/") {
+				imp.unmap(fmt.Sprintf("services.%s.volumes", name), fmt.Sprintf("%s is a CasaOS shared directory", strings.SplitN(s, ":", 2)[0]))
+			}
+		}
+	}
+
+	if entry.Service == "" {
+		entry.Service = firstService(services)
+	}
+	imp.addUI(entry)
+	return nil
+}
+
+// umbrel converts an Umbrel app, the metadata is in umbrel-app.yml and the
+// web interface is published through the app_proxy service
+func (imp *importer) umbrel(src ImportSource) error {
+	var meta map[string]interface{}
+	if err := yaml.Unmarshal(src.Metadata, &meta); err != nil {
+		return fmt.Errorf("failed to parse umbrel-app.yml: %w", err)
+	}
+	if meta == nil {
+		return fmt.Errorf("umbrel-app.yml is empty")
+	}
+	if err := imp.loadProject(src.Compose); err != nil {
+		return err
+	}
+
+	appID, _ := meta["id"].(string)
+	imp.vars["APP_DATA_DIR"] = "${TRIM_PKGVAR}"
+	if appID != "" {
+		imp.vars["APP_ID"] = appID
+	}
+
+	entry := UIEntry{}
+	var login []string
+	for _, key := range sortedKeys(meta) {
+		value := fmt.Sprint(meta[key])
+		switch key {
+		case "id":
+			imp.manifest["appname"] = importAppName(value)
+		case "name":
+			imp.manifest["display_name"] = value
+		case "tagline":
+			imp.manifest["desc"] = value
+		case "description":
+			if meta["tagline"] == nil {
+				imp.manifest["desc"] = value
+			} else {
+				imp.unmap("description", "the manifest desc uses the tagline")
+			}
+		case "version":
+			imp.manifest["version"] = value
+		case "developer":
+			imp.manifest["maintainer"] = value
+		case "website":
+			imp.manifest["maintainer_url"] = value
+		case "submitter":
+			imp.manifest["distributor"] = value
+		case "submission":
+			imp.manifest["distributor_url"] = value
+		case "icon":
+			imp.iconURL = value
+		case "port":
+			entry.Port = value
+		case "path":
+			entry.Path = value
+		case "defaultUsername", "defaultPassword":
+			if value != "" {
+				login = append(login, value)
+			}
+		case "manifestVersion":
+			// Version of the umbrel-app.yml format
+		default:
+			imp.unmap(key, "no fnOS equivalent")
+		}
+	}
+	if len(login) > 0 {
+		imp.tips("Default login: " + strings.Join(login, " / "))
+	}
+	if imp.iconURL == "" && appID != "" {
+		imp.iconURL = umbrelGallery + "/" + appID + "/icon.svg"
+	}
+
+	// The app proxy forwards the Umbrel port to APP_HOST:APP_PORT, the target
+	// service publishes the port itself on fnOS
+	services := imp.services()
+	if proxy, ok := services["app_proxy"].(map[string]interface{}); ok {
+		delete(services, "app_proxy")
+		entries, _ := keyValues(proxy["environment"], false)
+		var host, port string
+		for _, env := range entries {
+			switch env.key {
+			case "APP_HOST":
+				host = fmt.Sprint(env.value)
+			case "APP_PORT":
+				port = fmt.Sprint(env.value)
+			default:
+				imp.unmap("services.app_proxy.environment."+env.key, "the Umbrel app proxy is removed")
+			}
+		}
+		entry.Service = umbrelProxyTarget(host, appID, services)
+		target, _ := services[entry.Service].(map[string]interface{})
+		if target != nil && entry.Port != "" && port != "" {
+			ports, _ := target["ports"].([]interface{})
+			target["ports"] = append(ports, entry.Port+":"+port)
+		} else {
+			imp.unmap("services.app_proxy", fmt.Sprintf("proxy target %s:%s not found", host, port))
+		}
+	}
+
+	if entry.Service == "" {
+		entry.Service = firstService(services)
+	}
+	imp.addUI(entry)
+	return nil
+}
+
+// portainer converts a Portainer app template, container templates become a
+// service and stack templates use the compose file of the source
+func (imp *importer) portainer(src ImportSource) error {
+	var file interface{}
+	if err := json.Unmarshal(src.Metadata, &file); err != nil {
+		return fmt.Errorf("failed to parse templates JSON: %w", err)
+	}
+	// Version 2 and 3 files wrap the list in {"version", "templates"}
+	templates, _ := file.([]interface{})
+	if m, ok := file.(map[string]interface{}); ok {
+		templates, _ = m["templates"].([]interface{})
+	}
+
+	template, err := selectTemplate(templates, src.Template)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprint(template["title"])
+	appname := importAppName(title)
+	if name, ok := template["name"].(string); ok && name != "" {
+		appname = importAppName(name)
+	}
+	imp.manifest["appname"] = appname
+
+	stack := template["repository"] != nil
+	if stack {
+		if len(src.Compose) == 0 {
+			repo, _ := template["repository"].(map[string]interface{})
+			return fmt.Errorf("template %q is a stack template, download %v from %v and pass it as the compose file", title, repo["stackfile"], repo["url"])
+		}
+		if err := imp.loadProject(src.Compose); err != nil {
+			return err
+		}
+	} else {
+		imp.project = map[string]interface{}{"services": map[string]interface{}{appname: map[string]interface{}{}}}
+	}
+	services := imp.services()
+	var service map[string]interface{}
+	if !stack {
+		service, _ = services[appname].(map[string]interface{})
+	}
+
+	for _, key := range sortedKeys(template) {
+		value := template[key]
+		switch key {
+		case "title":
+			imp.manifest["display_name"] = title
+		case "description":
+			imp.manifest["desc"] = fmt.Sprint(value)
+		case "logo":
+			imp.iconURL = fmt.Sprint(value)
+		case "type", "name", "repository":
+			// Handled above
+		case "env":
+			envs, _ := value.([]interface{})
+			for _, e := range envs {
+				imp.portainerEnv(service, e)
+			}
+		default:
+			if stack || !imp.portainerContainer(service, key, value) {
+				imp.unmap(key, "no fnOS equivalent")
+			}
+		}
+	}
+
+	imp.addUI(UIEntry{Service: firstService(services)})
+	return nil
+}
+
+// portainerContainer sets a field of a container template on its service
+// Returns false for fields without compose equivalent
+func (imp *importer) portainerContainer(service map[string]interface{}, key string, value interface{}) bool {
+	switch key {
+	case "image", "hostname", "command", "privileged":
+		service[key] = value
+	case "restart_policy":
+		service["restart"] = value
+	case "interactive":
+		service["stdin_open"] = value
+		service["tty"] = value
+	case "ports":
+		service["ports"] = value
+	case "network":
+		switch value {
+		case "host", "bridge", "none":
+			service["network_mode"] = value
+		default:
+			return false
+		}
+	case "labels":
+		labels := make(map[string]interface{})
+		list, _ := value.([]interface{})
+		for _, item := range list {
+			label, _ := item.(map[string]interface{})
+			labels[fmt.Sprint(label["name"])] = fmt.Sprint(label["value"])
+		}
+		service["labels"] = labels
+	case "volumes":
+		// Volumes without bind path are kept in the app data directory
+		var volumes []interface{}
+		list, _ := value.([]interface{})
+		for _, item := range list {
+			volume, _ := item.(map[string]interface{})
+			target := fmt.Sprint(volume["container"])
+			source, _ := volume["bind"].(string)
+			if source == "" {
+				source = "${TRIM_PKGVAR}/" + path.Base(target)
+			}
+			mount := source + ":" + target
+			if volume["readonly"] == true {
+				mount += ":ro"
+			}
+			volumes = append(volumes, mount)
+		}
+		service["volumes"] = volumes
+	default:
+		return false
+	}
+	return true
+}
+
+// portainerEnv turns a template variable into a wizard field, preset variables
+// keep their default value
+func (imp *importer) portainerEnv(service map[string]interface{}, e interface{}) {
+	env, _ := e.(map[string]interface{})
+	name, _ := env["name"].(string)
+	if name == "" {
+		return
+	}
+	value := ""
+	if env["default"] != nil {
+		value = fmt.Sprint(env["default"])
+	}
+
+	item := importWizardItem{Label: env["label"], HelpText: env["description"]}
+	if options, ok := env["select"].([]interface{}); ok {
+		item.Type = "select"
+		for _, o := range options {
+			option, _ := o.(map[string]interface{})
+			item.Options = append(item.Options, importOption{Label: option["text"], Value: option["value"]})
+			if option["default"] == true {
+				value = fmt.Sprint(option["value"])
+			}
+		}
+	}
+
+	if service == nil {
+		// Stack templates reference the variables in the compose file
+		if env["preset"] == true {
+			imp.vars[name] = value
+		} else {
+			imp.vars[name] = "${" + imp.addWizardField(name, value, item) + "}"
+		}
+		return
+	}
+
+	entries, _ := keyValues(service["environment"], false)
+	if env["preset"] == true {
+		entries = append(entries, keyValue{name, value})
+	} else {
+		entries = append(entries, keyValue{name, "${" + imp.addWizardField(name, value, item) + "}"})
+	}
+	service["environment"] = buildKeyValues(entries, true)
+}
+
+// selectTemplate returns the Portainer template matching a title or name
+func selectTemplate(templates []interface{}, selector string) (map[string]interface{}, error) {
+	var titles []string
+	for _, t := range templates {
+		template, _ := t.(map[string]interface{})
+		if template == nil {
+			continue
+		}
+		title := fmt.Sprint(template["title"])
+		titles = append(titles, title)
+		if selector != "" && (strings.EqualFold(selector, title) || strings.EqualFold(selector, fmt.Sprint(template["name"]))) {
+			return template, nil
+		}
+	}
+
+	switch {
+	case len(titles) == 0:
+		return nil, fmt.Errorf("no templates found")
+	case selector != "":
+		return nil, fmt.Errorf("template %q not found (available: %s)", selector, strings.Join(titles, ", "))
+	case len(titles) > 1:
+		return nil, fmt.Errorf("%d templates found, select one by title or name (available: %s)", len(titles), strings.Join(titles, ", "))
+	}
+	return templates[0].(map[string]interface{}), nil
+}
+
+// umbrelProxyTarget returns the service an APP_HOST value points to,
+// written as "<app id>_<service>_1" or as the service name
+func umbrelProxyTarget(host, appID string, services map[string]interface{}) string {
+	name := host
+	for _, prefix := range []string{"$APP_ID_", "${APP_ID}_", appID + "_"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	name = strings.TrimSuffix(name, "_1")
+	if _, ok := services[name]; ok {
+		return name
+	}
+	for service, s := range services {
+		definition, _ := s.(map[string]interface{})
+		if fmt.Sprint(definition["container_name"]) == host {
+			return service
+		}
+	}
+	return ""
+}
+
+// loadProject parses the compose file of the source
+func (imp *importer) loadProject(data []byte) error {
+	if err := yaml.Unmarshal(data, &imp.project); err != nil {
+		return fmt.Errorf("failed to parse compose yaml: %w", err)
+	}
+	if imp.project == nil {
+		return fmt.Errorf("compose file is empty")
+	}
+	imp.normalizeServices()
+	return nil
+}
+
+// services returns the service definitions of the converted project
+func (imp *importer) services() map[string]interface{} {
+	services, _ := imp.project["services"].(map[string]interface{})
+	return services
+}
+
+// unmap records a source field that has no fnOS equivalent
+func (imp *importer) unmap(field, reason string) {
+	imp.unmapped = append(imp.unmapped, field+": "+reason)
+}
+
+// arch sets the manifest arch from a list of docker platforms, apps for both
+// amd64 and arm64 are noarch
+func (imp *importer) arch(field string, value interface{}) {
+	list, _ := value.([]interface{})
+	amd64, arm64 := false, false
+	for _, item := range list {
+		switch fmt.Sprint(item) {
+		case "amd64":
+			amd64 = true
+		case "arm64":
+			arm64 = true
+		default:
+			imp.unmap(field, fmt.Sprintf("fnOS does not run on %v", item))
+		}
+	}
+	switch {
+	case amd64 && arm64:
+		imp.manifest["arch"] = "noarch"
+	case amd64:
+		imp.manifest["arch"] = "x86_64"
+	case arm64:
+		imp.manifest["arch"] = "aarch64"
+	}
+}
+
+// localized converts a {locale: text} map of the source ("en_us", "zh_cn") to
+// the x-fnpack locales, a single translation is kept as plain text
+func (imp *importer) localized(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		if value == nil {
+			return nil
+		}
+		return fmt.Sprint(value)
+	}
+
+	texts := make(map[string]interface{})
+	for _, key := range sortedKeys(m) {
+		text := strings.TrimSpace(fmt.Sprint(m[key]))
+		if m[key] == nil || text == "" {
+			continue
+		}
+		locale, _, _ := strings.Cut(strings.ToLower(key), "_")
+		if _, taken := texts[locale]; taken {
+			locale = strings.ToLower(key)
+		}
+		texts[locale] = text
+	}
+
+	switch len(texts) {
+	case 0:
+		return nil
+	case 1:
+		for _, text := range texts {
+			return text
+		}
+	}
+	for locale := range texts {
+		imp.locales[locale] = true
+	}
+	return texts
+}
+
+// tips adds a tips item with the text to the install wizard
+func (imp *importer) tips(text interface{}) {
+	if text = imp.localized(text); text != nil {
+		imp.wizard = append(imp.wizard, importWizardItem{Type: "tips", HelpText: text})
+	}
+}
+
+// wizardEnv turns an environment variable of a service into a wizard field,
+// the current value becomes the initial value
+// Variables whose value references other variables are kept and listed as unmapped
+func (imp *importer) wizardEnv(serviceName string, service map[string]interface{}, key string, item importWizardItem) {
+	entries, isList := keyValues(service["environment"], false)
+	for i, entry := range entries {
+		if entry.key != key {
+			continue
+		}
+		value := ""
+		if entry.value != nil {
+			value = fmt.Sprint(entry.value)
+		}
+		if strings.Contains(value, "$") {
+			imp.unmap(fmt.Sprintf("services.%s.x-casaos.envs.%s", serviceName, key), fmt.Sprintf("value %s references a variable, no wizard field added", value))
+			return
+		}
+		entries[i].value = "${" + imp.addWizardField(key, value, item) + "}"
+		service["environment"] = buildKeyValues(entries, isList)
+		return
+	}
+	imp.unmap(fmt.Sprintf("services.%s.x-casaos.envs.%s", serviceName, key), "not set in the service environment")
+}
+
+// addWizardField adds a wizard field for a variable, once per variable
+// Returns the field name
+func (imp *importer) addWizardField(name, value string, item importWizardItem) string {
+	field := "wizard_" + strings.ToLower(name)
+	for _, existing := range imp.wizard {
+		if existing.Field == field {
+			return field
+		}
+	}
+
+	if item.Type == "" {
+		item.Type = "text"
+	}
+	if item.Label == nil {
+		item.Label = name
+	}
+	item.Field = field
+	item.InitValue = value
+	imp.wizard = append(imp.wizard, item)
+	return field
+}
+
+// addUI adds the desktop entry of the app, the title is the display name
+func (imp *importer) addUI(entry UIEntry) {
+	if entry.Service == "" {
+		return
+	}
+	if port := strings.TrimSpace(entry.Port); port == "" || strings.Contains(port, "$") {
+		// Default to the first host port of the service
+		entry.Port = ""
+	}
+	entry.Name = fmt.Sprint(imp.manifest["appname"])
+	if imp.manifest["appname"] == nil {
+		entry.Name = entry.Service
+	}
+	switch title := imp.manifest["display_name"].(type) {
+	case string:
+		entry.Title = title
+	case map[string]interface{}:
+		entry.Titles = make(map[string]string)
+		for locale, text := range title {
+			entry.Titles[locale] = fmt.Sprint(text)
+		}
+	}
+	imp.ui = append(imp.ui, entry)
+}
+
+// replaceStrings applies fn to every string of the service definitions
+func (imp *importer) replaceStrings(fn func(string) string) {
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case string:
+			return fn(t)
+		case map[string]interface{}:
+			for key, value := range t {
+				t[key] = walk(value)
+			}
+		case []interface{}:
+			for i, value := range t {
+				t[i] = walk(value)
+			}
+		}
+		return v
+	}
+	walk(imp.project["services"])
+}
+
+// replaceVariables substitutes the mapped source variables and reports the
+// others, runtime variables are kept
+func (imp *importer) replaceVariables() {
+	unmapped := make(map[string]bool)
+	imp.replaceStrings(func(s string) string {
+		return importVariablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+			match := importVariablePattern.FindStringSubmatch(ref)
+			name, modifier := match[1]+match[3], match[2]
+			if name == "" || IsRuntimeVariable(name) {
+				return ref
+			}
+			replacement, ok := imp.vars[name]
+			if !ok {
+				unmapped[name] = true
+				return ref
+			}
+			// Keep the modifier (default value) on variable replacements
+			if strings.HasPrefix(replacement, "${") && strings.HasSuffix(replacement, "}") {
+				return strings.TrimSuffix(replacement, "}") + modifier + "}"
+			}
+			return replacement
+		})
+	})
+
+	names := make([]string, 0, len(unmapped))
+	for name := range unmapped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		imp.unmap("$"+name, "no fnOS equivalent, set it in .env or replace it")
+	}
+}
+
+// normalizeServices rewrites the long syntax of service fields to the short
+// syntax of the compose model, reporting the options that are lost
+func (imp *importer) normalizeServices() {
+	services := imp.services()
+	for _, name := range sortedKeys(services) {
+		service, _ := services[name].(map[string]interface{})
+		if service == nil {
+			continue
+		}
+		field := func(key string) string { return fmt.Sprintf("services.%s.%s", name, key) }
+
+		if entries, isList := keyValues(service["environment"], false); !isList && entries != nil {
+			service["environment"] = buildKeyValues(entries, true)
+		}
+
+		if list, ok := service["ports"].([]interface{}); ok {
+			for i, item := range list {
+				port, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				short := fmt.Sprint(port["target"])
+				if port["published"] != nil {
+					short = fmt.Sprint(port["published"]) + ":" + short
+					if port["host_ip"] != nil {
+						short = fmt.Sprint(port["host_ip"]) + ":" + short
+					}
+				}
+				if protocol, ok := port["protocol"].(string); ok && protocol != "tcp" {
+					short += "/" + protocol
+				}
+				list[i] = short
+				for _, key := range sortedKeys(port) {
+					switch key {
+					case "target", "published", "host_ip", "protocol":
+					default:
+						imp.unmap(field("ports"), fmt.Sprintf("option %s of port %s is not kept", key, short))
+					}
+				}
+			}
+		}
+
+		if list, ok := service["volumes"].([]interface{}); ok {
+			var volumes []interface{}
+			for _, item := range list {
+				volume, ok := item.(map[string]interface{})
+				if !ok {
+					volumes = append(volumes, item)
+					continue
+				}
+				target := fmt.Sprint(volume["target"])
+				if volume["source"] == nil {
+					imp.unmap(field("volumes"), fmt.Sprintf("%v mount %s is not kept", volume["type"], target))
+					continue
+				}
+				short := fmt.Sprint(volume["source"]) + ":" + target
+				if volume["read_only"] == true {
+					short += ":ro"
+				}
+				volumes = append(volumes, short)
+				for _, key := range sortedKeys(volume) {
+					switch key {
+					case "type", "source", "target", "read_only":
+					default:
+						imp.unmap(field("volumes"), fmt.Sprintf("option %s of mount %s is not kept", key, target))
+					}
+				}
+			}
+			service["volumes"] = volumes
+		}
+
+		if m, ok := service["depends_on"].(map[string]interface{}); ok {
+			var names []interface{}
+			for _, dependency := range sortedKeys(m) {
+				names = append(names, dependency)
+				condition, _ := m[dependency].(map[string]interface{})
+				if c := condition["condition"]; c != nil && c != "service_started" {
+					imp.unmap(field("depends_on"), fmt.Sprintf("condition %v of %s is not kept", c, dependency))
+				}
+			}
+			service["depends_on"] = names
+		}
+
+		if m, ok := service["networks"].(map[string]interface{}); ok {
+			var names []interface{}
+			for _, network := range sortedKeys(m) {
+				names = append(names, network)
+				if m[network] != nil {
+					imp.unmap(field("networks"), fmt.Sprintf("options of network %s are not kept", network))
+				}
+			}
+			service["networks"] = names
+		}
+	}
+}
+
+// result assembles the converted compose file, x-fnpack first
+func (imp *importer) result() (*ImportResult, error) {
+	imp.replaceVariables()
+
+	xfnpack := &yaml.Node{Kind: yaml.MappingNode}
+	manifest := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range importManifestOrder {
+		if value := imp.manifest[key]; value != nil {
+			if err := appendNode(manifest, key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := appendNode(xfnpack, "manifest", manifest); err != nil {
+		return nil, err
+	}
+
+	// Localized texts without Chinese use English (or the first locale) as default
+	if len(imp.locales) > 0 && !imp.locales[DefaultLocale] {
+		locale := "en"
+		if !imp.locales[locale] {
+			names := make(map[string]string)
+			for name := range imp.locales {
+				names[name] = name
+			}
+			locale = SortedLocales(names)[0]
+		}
+		if err := appendNode(xfnpack, "i18n", I18nConfig{DefaultLocale: locale}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(imp.ui) > 0 {
+		if err := appendNode(xfnpack, "ui", imp.ui); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(imp.wizard) > 0 {
+		steps := []struct {
+			StepTitle string             `json:"stepTitle"`
+			Items     []importWizardItem `json:"items"`
+		}{{StepTitle: "Settings", Items: imp.wizard}}
+		wizard, err := json.MarshalIndent(steps, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal wizard JSON: %w", err)
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Style: yaml.LiteralStyle, Value: string(wizard) + "\n"}
+		if err := appendNode(xfnpack, "wizard/install", node); err != nil {
+			return nil, err
+		}
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	if err := appendNode(doc, "x-fnpack", xfnpack); err != nil {
+		return nil, err
+	}
+	keys := sortedKeys(imp.project)
+	sort.SliceStable(keys, func(i, j int) bool { return importKeyRank(keys[i]) < importKeyRank(keys[j]) })
+	for _, key := range keys {
+		if err := appendNode(doc, key, imp.project[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal compose yaml: %w", err)
+	}
+	content := buf.Bytes()
+	compose, err := ParseComposeContent(content)
+	if err != nil {
+		return nil, fmt.Errorf("converted compose file is invalid: %w", err)
+	}
+
+	return &ImportResult{
+		Compose:  compose,
+		Content:  content,
+		IconURL:  imp.iconURL,
+		Unmapped: imp.unmapped,
+	}, nil
+}
+
+// importKeyRank orders the top-level keys of converted compose files
+func importKeyRank(key string) int {
+	for i, first := range []string{"name", "services"} {
+		if key == first {
+			return i
+		}
+	}
+	return 2
+}
+
+// appendNode adds a key and its encoded value to a mapping node
+func appendNode(mapping *yaml.Node, key string, value interface{}) error {
+	node, ok := value.(*yaml.Node)
+	if !ok {
+		node = &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+	return nil
+}
+
+// firstService returns the first service name in sorted order
+func firstService(services map[string]interface{}) string {
+	if names := sortedKeys(services); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// importAppName converts a name to an fnOS appname (lowercase letters, digits and "-")
+func importAppName(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
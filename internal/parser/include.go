@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultIncludeDestination is where included files go without an explicit destination
+// Relative bind mounts of the compose file resolve against this directory
+const DefaultIncludeDestination = "app/docker/"
+
+// includeArrow separates the pattern from the destination in the string form
+const includeArrow = "->"
+
+// UnmarshalYAML decodes an include rule from either "<glob> [-> <destination>]" or an object
+func (r *IncludeRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		rule, err := ParseIncludeRule(value.Value)
+		if err != nil {
+			return err
+		}
+		*r = rule
+		return nil
+	}
+
+	// Decode through an alias type to avoid recursing into this method
+	type rawRule IncludeRule
+	var raw rawRule
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	if raw.From == "" {
+		return fmt.Errorf("include rule at line %d has no from pattern", value.Line)
+	}
+
+	*r = IncludeRule(raw)
+	return nil
+}
+
+// ParseIncludeRule parses the "<glob> [-> <destination>]" string form of an include rule
+func ParseIncludeRule(s string) (IncludeRule, error) {
+	from, to, _ := strings.Cut(s, includeArrow)
+	rule := IncludeRule{
+		From: strings.TrimSpace(from),
+		To:   strings.TrimSpace(to),
+	}
+	if rule.From == "" {
+		return IncludeRule{}, fmt.Errorf("include rule %q has no pattern", s)
+	}
+	return rule, nil
+}
+
+// Destination returns the destination of the rule, DefaultIncludeDestination if not set
+func (r IncludeRule) Destination() string {
+	if r.To == "" {
+		return DefaultIncludeDestination
+	}
+	return r.To
+}
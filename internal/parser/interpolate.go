@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LookupFunc returns the value of a variable and whether it is set
+type LookupFunc func(name string) (string, bool)
+
+// InterpolateOptions controls variable interpolation
+type InterpolateOptions struct {
+	// Lookup resolves variables
+	Lookup LookupFunc
+
+	// Missing, if set, is called for unset variables referenced without a default
+	Missing func(name string)
+
+	// Escape keeps the result valid for another round of interpolation by
+	// docker compose: $$ is kept and "$" in substituted values becomes "$$"
+	Escape bool
+
+	// Runtime resolves the runtime variables as well, as fnOS does when the app runs
+	Runtime bool
+}
+
+// runtimeVariablePrefixes are prefixes of variables resolved by fnOS when the app runs
+// (TRIM_* environment and wizard_* answers), they are never interpolated at build time
+var runtimeVariablePrefixes = []string{"TRIM_", "wizard_"}
+
+// IsRuntimeVariable reports whether a variable is resolved by fnOS at runtime
+func IsRuntimeVariable(name string) bool {
+	for _, prefix := range runtimeVariablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interpolate substitutes variables following the compose specification:
+// $VAR, ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error},
+// ${VAR:+replacement}, ${VAR+replacement} and $$ for a literal $
+// Runtime variables (see IsRuntimeVariable) are kept as written unless opts.Runtime is set
+func Interpolate(s string, opts InterpolateOptions) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			if opts.Escape {
+				out.WriteString("$$")
+			} else {
+				out.WriteByte('$')
+			}
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			value, err := interpolateBraced(s[i+2:end], s[i:end+1], opts)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = end
+		case isNameStart(next):
+			end := i + 1
+			for end < len(s) && isNameChar(s[end]) {
+				end++
+			}
+			name := s[i+1 : end]
+			if !opts.Runtime && IsRuntimeVariable(name) {
+				out.WriteString(s[i:end])
+			} else {
+				out.WriteString(escapeDollar(opts.lookup(name), opts.Escape))
+			}
+			i = end - 1
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// interpolateBraced resolves the expression inside ${...}, raw is the full ${...} text
+func interpolateBraced(expr, raw string, opts InterpolateOptions) (string, error) {
+	end := 0
+	for end < len(expr) && isNameChar(expr[end]) {
+		end++
+	}
+	name := expr[:end]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable %q", raw)
+	}
+	if !opts.Runtime && IsRuntimeVariable(name) {
+		return raw, nil
+	}
+
+	value, set := opts.Lookup(name)
+	nonEmpty := set && value != ""
+	rest := expr[end:]
+
+	var op string
+	for _, candidate := range []string{":-", ":?", ":+", "-", "?", "+"} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" && rest != "" {
+		return "", fmt.Errorf("invalid variable %q", raw)
+	}
+	arg := rest[len(op):]
+
+	switch op {
+	case "":
+		if !set && opts.Missing != nil {
+			opts.Missing(name)
+		}
+		return escapeDollar(value, opts.Escape), nil
+	case ":-", "-":
+		if nonEmpty || (op == "-" && set) {
+			return escapeDollar(value, opts.Escape), nil
+		}
+		return Interpolate(arg, opts)
+	case ":?", "?":
+		if nonEmpty || (op == "?" && set) {
+			return escapeDollar(value, opts.Escape), nil
+		}
+		messageOpts := opts
+		messageOpts.Escape = false
+		message, err := Interpolate(arg, messageOpts)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("required variable %s is missing a value: %s", name, message)
+	default: // ":+", "+"
+		if nonEmpty || (op == "+" && set) {
+			return Interpolate(arg, opts)
+		}
+		return "", nil
+	}
+}
+
+// lookup resolves a variable referenced without a default, reporting it if unset
+func (opts InterpolateOptions) lookup(name string) string {
+	value, set := opts.Lookup(name)
+	if !set && opts.Missing != nil {
+		opts.Missing(name)
+	}
+	return value
+}
+
+// matchingBrace returns the index of the brace closing the one at open, -1 if none
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// escapeDollar doubles "$" when the value is interpolated again later
+func escapeDollar(value string, escape bool) string {
+	if !escape {
+		return value
+	}
+	return strings.ReplaceAll(value, "$", "$$")
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// InterpolateCompose interpolates the values of compose content (see Interpolate)
+// Mapping keys and top-level extension fields (x-*) are left untouched, the
+// x-fnpack section holds scripts whose variables belong to the shell
+func InterpolateCompose(data []byte, opts InterpolateOptions) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse compose yaml: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if strings.HasPrefix(root.Content[i].Value, "x-") {
+			continue
+		}
+		if err := interpolateNode(root.Content[i+1], opts); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal interpolated yaml: %w", err)
+	}
+	return out, nil
+}
+
+// interpolateNode interpolates the scalar values below a YAML node
+func interpolateNode(node *yaml.Node, opts InterpolateOptions) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := Interpolate(node.Value, opts)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], opts); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolateNode(child, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ParseEnvFile parses .env content: KEY=VALUE lines, "#" comments, an optional
+// "export " prefix, single-quoted (literal) and double-quoted (escaped) values
+func ParseEnvFile(content []byte) (map[string]string, error) {
+	env := make(map[string]string)
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i+1)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = strings.TrimSpace(value[:idx])
+			}
+		}
+
+		env[key] = value
+	}
+
+	return env, nil
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MigrateProfile is the profile the migrate service of x-fnpack.lifecycle is
+// moved to, docker compose up skips it and upgrade_callback runs it explicitly
+const MigrateProfile = "fpk-migrate"
+
+// ApplyMigrate prepares the one-shot migrate service of x-fnpack.lifecycle
+// With keep set the service is moved to MigrateProfile, otherwise it is removed
+// as it is not part of the running app (UI entries, template variables)
+// Returns an error if the service does not exist
+func ApplyMigrate(data []byte, keep bool) ([]byte, error) {
+	var project map[string]interface{}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("failed to parse compose yaml: %w", err)
+	}
+
+	xfnpack, _ := project["x-fnpack"].(map[string]interface{})
+	lifecycle, _ := xfnpack["lifecycle"].(map[string]interface{})
+	name, _ := lifecycle["migrate"].(string)
+	if name == "" {
+		return data, nil
+	}
+
+	services, _ := project["services"].(map[string]interface{})
+	service, ok := services[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("migrate service %s not found (services with inactive profiles are dropped)", name)
+	}
+
+	if keep {
+		service["profiles"] = []interface{}{MigrateProfile}
+	} else {
+		delete(services, name)
+	}
+
+	out, err := yaml.Marshal(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal compose yaml: %w", err)
+	}
+	return out, nil
+}
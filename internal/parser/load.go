@@ -0,0 +1,633 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeFileError is an error in one of the compose files of a project
+type ComposeFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *ComposeFileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ComposeFileError) Unwrap() error {
+	return e.Err
+}
+
+// includedResources are the top-level sections merged from included files
+var includedResources = []string{"services", "networks", "volumes", "secrets", "configs"}
+
+// LoadCompose loads a compose project and returns it flattened into a single file
+// The first file is the main file, the others are overlays merged on top of it
+// (like repeated "docker compose -f"), followed by the overlays listed in
+// x-fnpack.compose_files of the main file (relative to the main file)
+// Top-level include and service extends are resolved, relative paths of files
+// in other directories are rebased onto the directory of the main file
+// Each file is interpolated with opts before merging
+func LoadCompose(files []string, opts InterpolateOptions) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no compose file given")
+	}
+
+	loader := &composeLoader{
+		opts:    opts,
+		baseDir: filepath.Dir(files[0]),
+		loading: make(map[string]bool),
+	}
+
+	project, err := loader.load(files[0])
+	if err != nil {
+		return nil, err
+	}
+
+	overlays := append([]string{}, files[1:]...)
+	for _, file := range composeFilesOption(project) {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(loader.baseDir, file)
+		}
+		overlays = append(overlays, file)
+	}
+
+	for _, file := range overlays {
+		overlay, err := loader.load(file)
+		if err != nil {
+			return nil, err
+		}
+		project = mergeProject(project, overlay)
+	}
+
+	out, err := yaml.Marshal(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal compose yaml: %w", err)
+	}
+	return out, nil
+}
+
+// composeFilesOption returns x-fnpack.compose_files of a parsed compose file
+func composeFilesOption(project map[string]interface{}) []string {
+	xfnpack, _ := project["x-fnpack"].(map[string]interface{})
+	list, _ := xfnpack["compose_files"].([]interface{})
+
+	var files []string
+	for _, item := range list {
+		if file, ok := item.(string); ok {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// composeLoader loads compose files, resolving include and extends
+type composeLoader struct {
+	opts InterpolateOptions
+
+	// baseDir is the directory of the main file, relative paths are rebased onto it
+	baseDir string
+
+	// loading tracks the files being loaded to detect include cycles
+	loading map[string]bool
+}
+
+// load reads, interpolates and parses a compose file, then resolves its
+// includes and extends and rebases its relative paths
+func (l *composeLoader) load(path string) (map[string]interface{}, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if l.loading[absPath] {
+		return nil, &ComposeFileError{Path: path, Err: fmt.Errorf("include cycle")}
+	}
+	l.loading[absPath] = true
+	defer delete(l.loading, absPath)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	data, err = InterpolateCompose(data, l.opts)
+	if err != nil {
+		return nil, &ComposeFileError{Path: path, Err: err}
+	}
+
+	var project map[string]interface{}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, &ComposeFileError{Path: path, Err: fmt.Errorf("failed to parse compose yaml: %w", err)}
+	}
+	if project == nil {
+		project = make(map[string]interface{})
+	}
+
+	dir := filepath.Dir(path)
+	l.rebase(project, dir)
+
+	if err := l.resolveIncludes(project, dir); err != nil {
+		return nil, &ComposeFileError{Path: path, Err: err}
+	}
+	if err := l.resolveExtends(project, dir); err != nil {
+		return nil, &ComposeFileError{Path: path, Err: err}
+	}
+
+	return project, nil
+}
+
+// resolveIncludes adds the resources of the files listed in the top-level include
+// Compose refuses conflicting definitions, identical ones (e.g., the same
+// external network declared in every file) are accepted
+func (l *composeLoader) resolveIncludes(project map[string]interface{}, dir string) error {
+	includes, ok := project["include"].([]interface{})
+	delete(project, "include")
+	if !ok {
+		return nil
+	}
+
+	for _, item := range includes {
+		var paths []string
+		switch v := item.(type) {
+		case string:
+			paths = []string{v}
+		case map[string]interface{}:
+			switch p := v["path"].(type) {
+			case string:
+				paths = []string{p}
+			case []interface{}:
+				for _, entry := range p {
+					if s, ok := entry.(string); ok {
+						paths = append(paths, s)
+					}
+				}
+			}
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("include entry has no path")
+		}
+
+		// Several paths form one project, the later ones are overlays
+		var included map[string]interface{}
+		for _, p := range paths {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			doc, err := l.load(p)
+			if err != nil {
+				return err
+			}
+			if included == nil {
+				included = doc
+			} else {
+				included = mergeProject(included, doc)
+			}
+		}
+
+		for _, section := range includedResources {
+			resources, _ := included[section].(map[string]interface{})
+			if len(resources) == 0 {
+				continue
+			}
+			target, _ := project[section].(map[string]interface{})
+			if target == nil {
+				target = make(map[string]interface{})
+				project[section] = target
+			}
+			for name, resource := range resources {
+				if existing, ok := target[name]; ok && !reflect.DeepEqual(existing, resource) {
+					return fmt.Errorf("%s %s from included file %s conflicts with an existing definition", strings.TrimSuffix(section, "s"), name, paths[0])
+				}
+				target[name] = resource
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveExtends merges the base service of every service with an extends key
+func (l *composeLoader) resolveExtends(project map[string]interface{}, dir string) error {
+	services, _ := project["services"].(map[string]interface{})
+	for name := range services {
+		if err := l.resolveService(services, name, dir, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveService resolves the extends chain of a service within services
+func (l *composeLoader) resolveService(services map[string]interface{}, name, dir string, stack []string) error {
+	for _, seen := range stack {
+		if seen == name {
+			return fmt.Errorf("extends cycle: %s", strings.Join(append(stack, name), " -> "))
+		}
+	}
+
+	service, _ := services[name].(map[string]interface{})
+	extends, ok := service["extends"]
+	if !ok {
+		return nil
+	}
+	delete(service, "extends")
+
+	var baseName, baseFile string
+	switch v := extends.(type) {
+	case string:
+		baseName = v
+	case map[string]interface{}:
+		baseName, _ = v["service"].(string)
+		baseFile, _ = v["file"].(string)
+	}
+	if baseName == "" {
+		return fmt.Errorf("service %s: extends has no service", name)
+	}
+
+	var base map[string]interface{}
+	if baseFile != "" {
+		if !filepath.IsAbs(baseFile) {
+			baseFile = filepath.Join(dir, baseFile)
+		}
+		doc, err := l.load(baseFile)
+		if err != nil {
+			return err
+		}
+		baseServices, _ := doc["services"].(map[string]interface{})
+		base, _ = baseServices[baseName].(map[string]interface{})
+		if base == nil {
+			return fmt.Errorf("service %s: extended service %s not found in %s", name, baseName, baseFile)
+		}
+	} else {
+		if _, ok := services[baseName]; !ok {
+			return fmt.Errorf("service %s: extended service %s not found", name, baseName)
+		}
+		if err := l.resolveService(services, baseName, dir, append(stack, name)); err != nil {
+			return err
+		}
+		base, _ = services[baseName].(map[string]interface{})
+	}
+
+	services[name] = mergeService(deepCopy(base).(map[string]interface{}), service)
+	return nil
+}
+
+// rebase rewrites the relative paths of a file in dir so they resolve from the main file
+func (l *composeLoader) rebase(project map[string]interface{}, dir string) {
+	if filepath.Clean(dir) == filepath.Clean(l.baseDir) {
+		return
+	}
+
+	services, _ := project["services"].(map[string]interface{})
+	for _, s := range services {
+		service, _ := s.(map[string]interface{})
+		if service == nil {
+			continue
+		}
+
+		if volumes, ok := service["volumes"].([]interface{}); ok {
+			for i, volume := range volumes {
+				switch v := volume.(type) {
+				case string:
+					source, rest, found := strings.Cut(v, ":")
+					if found && strings.HasPrefix(source, ".") {
+						volumes[i] = l.rebasePath(source, dir) + ":" + rest
+					}
+				case map[string]interface{}:
+					if source, ok := v["source"].(string); ok && v["type"] == "bind" && !filepath.IsAbs(source) {
+						v["source"] = l.rebasePath(source, dir)
+					}
+				}
+			}
+		}
+
+		switch v := service["env_file"].(type) {
+		case string:
+			service["env_file"] = l.rebasePath(v, dir)
+		case []interface{}:
+			for i, file := range v {
+				if s, ok := file.(string); ok {
+					v[i] = l.rebasePath(s, dir)
+				}
+			}
+		}
+
+		switch v := service["build"].(type) {
+		case string:
+			service["build"] = l.rebasePath(v, dir)
+		case map[string]interface{}:
+			if context, ok := v["context"].(string); ok {
+				v["context"] = l.rebasePath(context, dir)
+			}
+		}
+	}
+}
+
+// rebasePath rewrites a path relative to dir as a "./"-prefixed path relative to baseDir
+func (l *composeLoader) rebasePath(p, dir string) string {
+	if filepath.IsAbs(p) || strings.Contains(p, "://") {
+		return p
+	}
+	rel, err := filepath.Rel(l.baseDir, filepath.Join(dir, p))
+	if err != nil {
+		return p
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, "../") && rel != ".." {
+		rel = "./" + rel
+	}
+	return rel
+}
+
+// mergeProject merges an overlay file onto a project following the compose merge rules
+func mergeProject(base, overlay map[string]interface{}) map[string]interface{} {
+	for key, value := range overlay {
+		if key != "services" {
+			base[key] = mergeValue(key, base[key], value)
+			continue
+		}
+
+		services, _ := base["services"].(map[string]interface{})
+		if services == nil {
+			services = make(map[string]interface{})
+			base["services"] = services
+		}
+		overlayServices, _ := value.(map[string]interface{})
+		for name, s := range overlayServices {
+			service, _ := s.(map[string]interface{})
+			if existing, ok := services[name].(map[string]interface{}); ok {
+				services[name] = mergeService(existing, service)
+			} else {
+				services[name] = service
+			}
+		}
+	}
+	return base
+}
+
+// mergeService merges an overriding service definition onto a base service
+func mergeService(base, override map[string]interface{}) map[string]interface{} {
+	for key, value := range override {
+		switch key {
+		case "command", "entrypoint":
+			// Replaced as a whole, merging arguments would make no sense
+			base[key] = value
+		case "environment", "labels", "annotations", "sysctls", "extra_hosts":
+			base[key] = mergeKeyValues(base[key], value, key == "extra_hosts")
+		case "volumes", "devices":
+			base[key] = mergeMounts(base[key], value)
+		case "depends_on", "networks":
+			base[key] = mergeNamed(key, base[key], value)
+		default:
+			base[key] = mergeValue(key, base[key], value)
+		}
+	}
+	return base
+}
+
+// mergeValue merges mappings recursively, appends sequences without duplicates
+// and lets scalars of the override win
+func mergeValue(key string, base, override interface{}) interface{} {
+	switch o := override.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return o
+		}
+		for k, v := range o {
+			b[k] = mergeValue(k, b[k], v)
+		}
+		return b
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || key == "test" {
+			// healthcheck.test is a command, replaced as a whole
+			return o
+		}
+		for _, item := range o {
+			if !containsValue(b, item) {
+				b = append(b, item)
+			}
+		}
+		return b
+	default:
+		return override
+	}
+}
+
+// containsValue reports whether list contains an item equal to value
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyValue is an entry of a list or map of key/value pairs
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
+// keyValues reads "KEY=VALUE" lists (also "host:ip" with colon set) and maps in order
+func keyValues(v interface{}, colon bool) ([]keyValue, bool) {
+	var entries []keyValue
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			s := fmt.Sprint(item)
+			key, value, found := strings.Cut(s, "=")
+			if !found && colon {
+				key, value, found = strings.Cut(s, ":")
+			}
+			if found {
+				entries = append(entries, keyValue{key, value})
+			} else {
+				entries = append(entries, keyValue{key, nil})
+			}
+		}
+		return entries, true
+	case map[string]interface{}:
+		for _, key := range sortedKeys(t) {
+			entries = append(entries, keyValue{key, t[key]})
+		}
+		return entries, false
+	}
+	return nil, false
+}
+
+// mergeKeyValues merges key/value pairs by key, keeping the form of the base
+func mergeKeyValues(base, override interface{}, colon bool) interface{} {
+	if base == nil {
+		return override
+	}
+
+	baseEntries, isList := keyValues(base, colon)
+	overrideEntries, _ := keyValues(override, colon)
+
+	index := make(map[string]int)
+	for i, entry := range baseEntries {
+		index[entry.key] = i
+	}
+	for _, entry := range overrideEntries {
+		if i, ok := index[entry.key]; ok {
+			baseEntries[i] = entry
+		} else {
+			index[entry.key] = len(baseEntries)
+			baseEntries = append(baseEntries, entry)
+		}
+	}
+
+	return buildKeyValues(baseEntries, isList)
+}
+
+// buildKeyValues converts key/value pairs back to a "KEY=VALUE" list or a map
+func buildKeyValues(entries []keyValue, isList bool) interface{} {
+	if isList {
+		list := make([]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			if entry.value == nil {
+				list = append(list, entry.key)
+			} else {
+				list = append(list, fmt.Sprintf("%s=%v", entry.key, entry.value))
+			}
+		}
+		return list
+	}
+
+	m := make(map[string]interface{})
+	for _, entry := range entries {
+		m[entry.key] = entry.value
+	}
+	return m
+}
+
+// mountTarget returns the container path of a volume or device entry
+func mountTarget(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		parts := strings.Split(t, ":")
+		if len(parts) == 1 {
+			return parts[0]
+		}
+		return parts[1]
+	case map[string]interface{}:
+		target, _ := t["target"].(string)
+		return target
+	}
+	return ""
+}
+
+// mergeMounts merges volume or device lists by container path
+func mergeMounts(base, override interface{}) interface{} {
+	b, ok := base.([]interface{})
+	o, ok2 := override.([]interface{})
+	if !ok || !ok2 {
+		return override
+	}
+
+	for _, item := range o {
+		target := mountTarget(item)
+		replaced := false
+		for i, existing := range b {
+			if target != "" && mountTarget(existing) == target {
+				b[i] = item
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			b = append(b, item)
+		}
+	}
+	return b
+}
+
+// mergeNamed merges depends_on or networks given as a list of names or a map
+// Two lists stay a list, otherwise the result is a map
+func mergeNamed(key string, base, override interface{}) interface{} {
+	b, baseIsList := base.([]interface{})
+	o, overrideIsList := override.([]interface{})
+	if base == nil {
+		return override
+	}
+	if baseIsList && overrideIsList {
+		for _, item := range o {
+			if !containsValue(b, item) {
+				b = append(b, item)
+			}
+		}
+		return b
+	}
+
+	m := toNamedMap(key, base)
+	for name, value := range toNamedMap(key, override) {
+		if existing, ok := m[name].(map[string]interface{}); ok {
+			if v, ok := value.(map[string]interface{}); ok {
+				m[name] = mergeValue(name, existing, v)
+				continue
+			}
+		}
+		if value != nil || m[name] == nil {
+			m[name] = value
+		}
+	}
+	return m
+}
+
+// toNamedMap converts a list of names to the map form
+// depends_on entries get the default condition, networks entries stay empty
+func toNamedMap(key string, v interface{}) map[string]interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return t
+	case []interface{}:
+		m := make(map[string]interface{})
+		for _, item := range t {
+			if key == "depends_on" {
+				m[fmt.Sprint(item)] = map[string]interface{}{"condition": "service_started"}
+			} else {
+				m[fmt.Sprint(item)] = nil
+			}
+		}
+		return m
+	}
+	return make(map[string]interface{})
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deepCopy copies maps and slices of a decoded YAML value
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = deepCopy(val)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, val := range t {
+			list[i] = deepCopy(val)
+		}
+		return list
+	}
+	return v
+}
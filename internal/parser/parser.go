@@ -41,14 +41,30 @@ func ParseComposeContent(data []byte) (*ComposeFile, error) {
 	return &compose, nil
 }
 
+// reservedKeys are x-fnpack keys holding builder options rather than file content
+var reservedKeys = map[string]bool{
+	"manifest":      true,
+	"changelog":     true,
+	"compose_files": true,
+	"hooks":         true,
+	"i18n":          true,
+	"icon":          true,
+	"icon_style":    true,
+	"include":       true,
+	"lifecycle":     true,
+	"profiles":      true,
+	"ui":            true,
+	"version_from":  true,
+}
+
 // extractCustomFiles extracts all file paths and contents from x-fnpack
-// All keys except "manifest" are treated as file paths with multi-line text content
+// All keys except the reserved option keys are treated as file paths with multi-line text content
 func extractCustomFiles(xfnpack map[string]interface{}) map[string]string {
 	files := make(map[string]string)
 
 	for key, value := range xfnpack {
-		// Skip manifest - it's handled separately as YAML object -> key=value
-		if key == "manifest" {
+		// Skip option keys - manifest is handled separately as YAML object -> key=value
+		if reservedKeys[key] {
 			continue
 		}
 
@@ -93,8 +109,9 @@ func ExtractVariables(compose *ComposeFile) Variables {
 		vars.FirstPort = extractHostPort(firstService.Ports[0])
 	}
 
-	// Extract image organization and name
+	// Extract image organization, name and tag
 	vars.ImageOrg, vars.ImageName = extractImageInfo(firstService.Image)
+	vars.ImageTag = extractImageTag(firstService.Image)
 
 	return vars
 }
@@ -134,6 +151,22 @@ func extractImageInfo(image string) (org, name string) {
 	}
 }
 
+// extractImageTag extracts the tag from a docker image string
+// Examples:
+//   - "lobehub/lobe-chat:1.2.3" -> "1.2.3"
+//   - "registry:5000/app" -> ""
+//   - "app:1.0@sha256:..." -> "1.0"
+func extractImageTag(image string) string {
+	// Drop the digest
+	image, _, _ = strings.Cut(image, "@")
+
+	idx := strings.LastIndex(image, ":")
+	if idx == -1 || strings.Contains(image[idx:], "/") {
+		return ""
+	}
+	return image[idx+1:]
+}
+
 // extractHostPort extracts the host port from a port mapping string
 // Supports formats: "3000", "3000:8080", "0.0.0.0:3000:8080"
 func extractHostPort(portMapping string) string {
@@ -1,6 +1,10 @@
 package parser
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -251,3 +255,678 @@ func TestExtractVariables_WithImage(t *testing.T) {
 		t.Errorf("Expected ImageName 'lobe-chat', got %s", vars.ImageName)
 	}
 }
+
+func TestExtractUIEntries(t *testing.T) {
+	content := []byte(`
+x-fnpack:
+  ui:
+    - grafana
+    - service: admin
+      title: "Admin Panel"
+      protocol: https
+      port: 8443
+      path: /admin/
+      icon: admin.png
+
+services:
+  admin:
+    image: myorg/admin
+    ports:
+      - 9000:9000
+  grafana:
+    image: grafana/grafana
+    ports:
+      - 3000:3000
+  prometheus:
+    image: prom/prometheus
+    ports:
+      - 9090:9090
+    labels:
+      - com.fnpack.ui=true
+      - com.fnpack.ui.title=Prometheus
+  db:
+    image: postgres
+`)
+
+	compose, err := ParseComposeContent(content)
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+
+	entries := ExtractUIEntries(compose)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 UI entries, got %d: %+v", len(entries), entries)
+	}
+
+	expected := []UIEntry{
+		{Name: "grafana", Service: "grafana", Title: "grafana", Type: UIModePort, Protocol: "http", Port: "3000", Path: "/"},
+		{Name: "admin", Service: "admin", Title: "Admin Panel", Type: UIModePort, Protocol: "https", Port: "8443", Path: "/admin/", Icon: "admin.png"},
+		{Name: "prometheus", Service: "prometheus", Title: "Prometheus", Type: UIModePort, Protocol: "http", Port: "9090", Path: "/"},
+	}
+
+	for i, want := range expected {
+		if !reflect.DeepEqual(entries[i], want) {
+			t.Errorf("entry %d = %+v, expected %+v", i, entries[i], want)
+		}
+	}
+}
+
+func TestLabels_MapForm(t *testing.T) {
+	content := []byte(`
+services:
+  app:
+    image: nginx
+    labels:
+      com.fnpack.ui: "true"
+`)
+
+	compose, err := ParseComposeContent(content)
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+
+	if compose.Services["app"].Labels[LabelUI] != "true" {
+		t.Errorf("Expected label %s=true, got %v", LabelUI, compose.Services["app"].Labels)
+	}
+}
+
+func TestExtractUIEntries_PathMode(t *testing.T) {
+	compose := &ComposeFile{
+		XFnpack: XFnpack{
+			UI: []UIEntry{{Service: "web", Type: UIModePath}},
+		},
+		Services: map[string]Service{
+			"web": {Ports: []string{"8080:80"}},
+		},
+	}
+
+	entries := ExtractUIEntries(compose)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 UI entry, got %d", len(entries))
+	}
+
+	want := UIEntry{Name: "web", Service: "web", Title: "web", Type: UIModePath, Path: "/web/"}
+	if !reflect.DeepEqual(entries[0], want) {
+		t.Errorf("entry = %+v, expected %+v", entries[0], want)
+	}
+}
+
+func TestExtractUIEntries_LocalizedTitle(t *testing.T) {
+	content := []byte(`
+x-fnpack:
+  i18n:
+    default_locale: en
+  ui:
+    - service: web
+      title:
+        zh: "网页"
+        en: "Web"
+services:
+  web:
+    ports:
+      - 8080:80
+`)
+
+	compose, err := ParseComposeContent(content)
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+
+	entries := ExtractUIEntries(compose)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 UI entry, got %d", len(entries))
+	}
+
+	if entries[0].Title != "Web" {
+		t.Errorf("Expected default-locale title 'Web', got %q", entries[0].Title)
+	}
+
+	if entries[0].Titles["zh"] != "网页" {
+		t.Errorf("Expected zh title '网页', got %q", entries[0].Titles["zh"])
+	}
+}
+
+
+func TestUIEntryMarshalYAML(t *testing.T) {
+	entries := []UIEntry{
+		{Name: "web", Service: "web", Title: "Web", Port: "8080"},
+		{Service: "admin", Titles: map[string]string{"en": "Admin", "zh": "管理"}, Type: "path"},
+		{Service: "api"},
+	}
+
+	out, err := yaml.Marshal(entries)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `- name: web
+  service: web
+  title: Web
+  port: "8080"
+- service: admin
+  title:
+    en: Admin
+    zh: 管理
+  type: path
+- service: api
+`
+	if string(out) != expected {
+		t.Errorf("Marshal = %s, expected %s", out, expected)
+	}
+
+	var decoded []UIEntry
+	if err := yaml.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, entries) {
+		t.Errorf("round trip = %+v, expected %+v", decoded, entries)
+	}
+}
+func TestResolveLocale(t *testing.T) {
+	values := map[string]string{"zh": "浏览器", "en": "Browser"}
+
+	tests := []struct {
+		locale   string
+		def      string
+		expected string
+	}{
+		{"en", "zh", "Browser"},
+		{"ja", "zh", "浏览器"},
+		{"ja", "fr", "Browser"},
+	}
+
+	for _, tt := range tests {
+		result := ResolveLocale(values, tt.locale, tt.def)
+		if result != tt.expected {
+			t.Errorf("ResolveLocale(%q, %q) = %q, expected %q", tt.locale, tt.def, result, tt.expected)
+		}
+	}
+}
+
+func TestExtractImageTag(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"alpine", ""},
+		{"lobehub/lobe-chat:1.2.3", "1.2.3"},
+		{"registry.example.com:5000/org/app", ""},
+		{"registry.example.com:5000/org/app:v2.0", "v2.0"},
+		{"app:1.0@sha256:abcdef", "1.0"},
+	}
+
+	for _, tt := range tests {
+		if result := extractImageTag(tt.image); result != tt.expected {
+			t.Errorf("extractImageTag(%q) = %q, expected %q", tt.image, result, tt.expected)
+		}
+	}
+}
+
+func TestParseComposeContent_Include(t *testing.T) {
+	content := `
+x-fnpack:
+  include:
+    - conf/** -> app/docker/conf/
+    - sql/*.sql
+    - from: env.tpl
+      to: app/docker/.env.example
+services:
+  app:
+    image: app
+`
+	compose, err := ParseComposeContent([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+
+	expected := []IncludeRule{
+		{From: "conf/**", To: "app/docker/conf/"},
+		{From: "sql/*.sql"},
+		{From: "env.tpl", To: "app/docker/.env.example"},
+	}
+	if !reflect.DeepEqual(compose.XFnpack.Include, expected) {
+		t.Errorf("Include = %+v, expected %+v", compose.XFnpack.Include, expected)
+	}
+	if _, ok := compose.XFnpack.Files["include"]; ok {
+		t.Error("include should not be treated as a custom file")
+	}
+	if dest := compose.XFnpack.Include[1].Destination(); dest != DefaultIncludeDestination {
+		t.Errorf("Destination() = %q, expected %q", dest, DefaultIncludeDestination)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"PORT": "9090", "EMPTY": "", "PRICE": "5$"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		input    string
+		escape   bool
+		expected string
+	}{
+		{"${PORT:-8080}:80", false, "9090:80"},
+		{"${UNSET:-8080}:80", false, "8080:80"},
+		{"${EMPTY:-a}|${EMPTY-b}", false, "a|"},
+		{"${PORT:+set}${UNSET:+set}", false, "set"},
+		{"$PORT/${UNSET}", false, "9090/"},
+		{"${UNSET:-${PORT}}", false, "9090"},
+		{"$$HOME ${PRICE}", false, "$HOME 5$"},
+		{"$$HOME ${PRICE}", true, "$$HOME 5$$"},
+		{"${TRIM_PKGVAR}/data $wizard_port ${wizard_user:-admin}", true, "${TRIM_PKGVAR}/data $wizard_port ${wizard_user:-admin}"},
+	}
+
+	for _, tt := range tests {
+		result, err := Interpolate(tt.input, InterpolateOptions{Lookup: lookup, Escape: tt.escape})
+		if err != nil {
+			t.Fatalf("Interpolate(%q) failed: %v", tt.input, err)
+		}
+		if result != tt.expected {
+			t.Errorf("Interpolate(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+
+	// Runtime resolves the variables fnOS sets, as when previewing the installed app
+	env["TRIM_PKGVAR"] = "/var/apps/app/var"
+	result, err := Interpolate("${TRIM_PKGVAR}/data ${wizard_user:-admin}", InterpolateOptions{Lookup: lookup, Runtime: true})
+	if err != nil || result != "/var/apps/app/var/data admin" {
+		t.Errorf("Interpolate with Runtime = %q, %v", result, err)
+	}
+
+	if _, err := Interpolate("${UNSET:?must be set}", InterpolateOptions{Lookup: lookup}); err == nil {
+		t.Error("expected error for required variable")
+	}
+	if _, err := Interpolate("${PORT", InterpolateOptions{Lookup: lookup}); err == nil {
+		t.Error("expected error for unterminated variable")
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	content := "# comment\nexport A=1\nB = two # note\nC='lit $x'\nD=\"a\\nb\"\n\n"
+	env, err := ParseEnvFile([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseEnvFile failed: %v", err)
+	}
+
+	expected := map[string]string{"A": "1", "B": "two", "C": "lit $x", "D": "a\nb"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("ParseEnvFile = %v, expected %v", env, expected)
+	}
+}
+
+func TestLoadCompose(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"compose.yaml": `
+x-fnpack:
+  compose_files: [compose.prod.yaml]
+include:
+  - lib/db.yaml
+services:
+  web:
+    extends:
+      file: lib/common.yaml
+      service: common
+    image: web:${TAG:-1.0}
+    environment:
+      - MODE=dev
+`,
+		"compose.prod.yaml": `
+services:
+  web:
+    environment:
+      MODE: prod
+    ports: ["443:443"]
+`,
+		"lib/common.yaml": `
+services:
+  common:
+    restart: always
+    environment: [TZ=UTC]
+    volumes: ["./conf:/etc/app"]
+`,
+		"lib/db.yaml": `
+services:
+  db:
+    image: postgres
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lookup := func(name string) (string, bool) { return "", false }
+	data, err := LoadCompose([]string{filepath.Join(dir, "compose.yaml")}, InterpolateOptions{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("LoadCompose failed: %v", err)
+	}
+	compose, err := ParseComposeContent(data)
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+
+	web := compose.Services["web"]
+	if web.Image != "web:1.0" || web.Restart != "always" {
+		t.Errorf("web image/restart = %q/%q", web.Image, web.Restart)
+	}
+	if !reflect.DeepEqual(web.Environment, []string{"TZ=UTC", "MODE=prod"}) {
+		t.Errorf("web environment = %v", web.Environment)
+	}
+	if !reflect.DeepEqual(web.Volumes, []string{"./lib/conf:/etc/app"}) {
+		t.Errorf("web volumes = %v, expected the bind mount rebased onto the main file", web.Volumes)
+	}
+	if !reflect.DeepEqual(web.Ports, []string{"443:443"}) {
+		t.Errorf("web ports = %v", web.Ports)
+	}
+	if _, ok := compose.Services["db"]; !ok {
+		t.Error("included service db is missing")
+	}
+}
+
+func TestApplyProfiles(t *testing.T) {
+	content := []byte(`
+x-fnpack:
+  profiles: [mail]
+services:
+  app:
+    image: app
+  adminer:
+    image: adminer
+    profiles: [dev]
+  mailhog:
+    image: mailhog
+    profiles: [dev, mail]
+`)
+
+	data, dropped, err := ApplyProfiles(content, nil)
+	if err != nil {
+		t.Fatalf("ApplyProfiles failed: %v", err)
+	}
+	if !reflect.DeepEqual(dropped, []string{"adminer"}) {
+		t.Errorf("dropped = %v, expected [adminer]", dropped)
+	}
+	compose, err := ParseComposeContent(data)
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+	if len(compose.Services) != 2 {
+		t.Errorf("expected 2 services, got %d", len(compose.Services))
+	}
+
+	// --profile overrides x-fnpack.profiles
+	_, dropped, err = ApplyProfiles(content, []string{"dev"})
+	if err != nil {
+		t.Fatalf("ApplyProfiles failed: %v", err)
+	}
+	if len(dropped) != 0 {
+		t.Errorf("dropped = %v, expected none", dropped)
+	}
+
+	dangling := []byte(`
+services:
+  app:
+    image: app
+    depends_on: [adminer]
+  adminer:
+    image: adminer
+    profiles: [dev]
+`)
+	if _, _, err := ApplyProfiles(dangling, nil); err == nil {
+		t.Error("expected error for depends_on on a dropped service")
+	}
+}
+
+func TestApplySecrets(t *testing.T) {
+	content := []byte(`
+services:
+  db:
+    image: postgres:16
+    environment:
+      - POSTGRES_PASSWORD=${wizard_db_password}
+  web:
+    image: example/web
+    labels:
+      com.fnpack.secret-file-env: "true"
+    environment:
+      API_KEY: $wizard_api_key
+      DB_URL: postgres://app:${wizard_db_password}@db/app
+`)
+	secrets := []Secret{
+		{Name: "api_key", Field: "wizard_api_key"},
+		{Name: "db_password", Field: "wizard_db_password"},
+		{Name: "unused", Field: "wizard_unused"},
+	}
+
+	data, warnings, err := ApplySecrets(content, secrets)
+	if err != nil {
+		t.Fatalf("ApplySecrets failed: %v", err)
+	}
+
+	var project struct {
+		Secrets  map[string]map[string]string `yaml:"secrets"`
+		Services map[string]struct {
+			Environment interface{} `yaml:"environment"`
+			Secrets     []string    `yaml:"secrets"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		t.Fatalf("failed to parse result: %v", err)
+	}
+
+	if got := project.Secrets["db_password"]["file"]; got != "${TRIM_PKGVAR}/secrets/db_password" {
+		t.Errorf("db_password file = %q", got)
+	}
+	if !reflect.DeepEqual(project.Services["db"].Environment, []interface{}{"POSTGRES_PASSWORD_FILE=/run/secrets/db_password"}) {
+		t.Errorf("db environment = %v", project.Services["db"].Environment)
+	}
+	if !reflect.DeepEqual(project.Services["web"].Secrets, []string{"api_key"}) {
+		t.Errorf("web secrets = %v, expected [api_key]", project.Services["web"].Secrets)
+	}
+	env, _ := project.Services["web"].Environment.(map[string]interface{})
+	if env["API_KEY_FILE"] != "/run/secrets/api_key" || env["DB_URL"] == nil {
+		t.Errorf("web environment = %v", env)
+	}
+
+	// The embedded value and the unused field are reported
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %v", warnings)
+	}
+}
+
+func TestApplyMigrate(t *testing.T) {
+	content := []byte(`
+x-fnpack:
+  lifecycle:
+    migrate: migrate
+services:
+  app:
+    image: app
+  migrate:
+    image: app
+    command: migrate
+`)
+
+	data, err := ApplyMigrate(content, true)
+	if err != nil {
+		t.Fatalf("ApplyMigrate failed: %v", err)
+	}
+	var project struct {
+		Services map[string]struct {
+			Profiles []string `yaml:"profiles"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		t.Fatalf("failed to parse result: %v", err)
+	}
+	if !reflect.DeepEqual(project.Services["migrate"].Profiles, []string{MigrateProfile}) {
+		t.Errorf("migrate profiles = %v, expected [%s]", project.Services["migrate"].Profiles, MigrateProfile)
+	}
+
+	// Without keep the service is not part of the app
+	data, err = ApplyMigrate(content, false)
+	if err != nil {
+		t.Fatalf("ApplyMigrate failed: %v", err)
+	}
+	compose, err := ParseComposeContent(data)
+	if err != nil {
+		t.Fatalf("ParseComposeContent failed: %v", err)
+	}
+	if _, ok := compose.Services["migrate"]; ok || len(compose.Services) != 1 {
+		t.Errorf("expected only app, got %v", compose.Services)
+	}
+
+	missing := []byte(`
+x-fnpack:
+  lifecycle:
+    migrate: migrate
+services:
+  app:
+    image: app
+`)
+	if _, err := ApplyMigrate(missing, true); err == nil {
+		t.Error("expected error for a missing migrate service")
+	}
+}
+
+func TestImport(t *testing.T) {
+	casaos := []byte(`
+name: whoami
+services:
+  whoami:
+    image: traefik/whoami:v1.10
+    environment:
+      PUID: $PUID
+      TZ: $TZ
+      WHOAMI_NAME: demo
+    ports:
+      - target: 80
+        published: "8080"
+        protocol: tcp
+    volumes:
+      - /DATA/AppData/$AppID/data:/data
+    x-casaos:
+      envs:
+        - container: WHOAMI_NAME
+          description:
+            en_us: Instance name
+        - container: TZ
+          description:
+            en_us: Time zone
+x-casaos:
+  main: whoami
+  title:
+    en_us: Whoami
+  tagline:
+    en_us: Tiny web server
+  icon: https://example.com/whoami.png
+  port_map: "8080"
+  category: Developer
+`)
+
+	result, err := Import("casaos", ImportSource{Compose: casaos})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	manifest := result.Compose.XFnpack.Manifest
+	if manifest["appname"] != "whoami" || manifest["display_name"] != "Whoami" || manifest["desc"] != "Tiny web server" {
+		t.Errorf("unexpected manifest: %v", manifest)
+	}
+	if result.IconURL != "https://example.com/whoami.png" {
+		t.Errorf("IconURL = %q", result.IconURL)
+	}
+	service := result.Compose.Services["whoami"]
+	expectedEnv := []string{"PUID=${TRIM_UID}", "TZ=$TZ", "WHOAMI_NAME=${wizard_whoami_name}"}
+	if !reflect.DeepEqual(service.Environment, expectedEnv) {
+		t.Errorf("environment = %v, expected %v", service.Environment, expectedEnv)
+	}
+	if !reflect.DeepEqual(service.Ports, []string{"8080:80"}) || !reflect.DeepEqual(service.Volumes, []string{"${TRIM_PKGVAR}/data:/data"}) {
+		t.Errorf("ports = %v, volumes = %v", service.Ports, service.Volumes)
+	}
+	if wizard := result.Compose.XFnpack.Files["wizard/install"]; !strings.Contains(wizard, `"label": "Instance name"`) || !strings.Contains(wizard, `"initValue": "demo"`) {
+		t.Errorf("unexpected wizard: %s", wizard)
+	}
+	entries := ExtractUIEntries(result.Compose)
+	if len(entries) != 1 || entries[0].Service != "whoami" || entries[0].Port != "8080" || entries[0].Title != "Whoami" {
+		t.Errorf("unexpected ui entries: %+v", entries)
+	}
+	expectedUnmapped := []string{
+		"x-casaos.category: no fnOS equivalent",
+		"services.whoami.x-casaos.envs.TZ: value $TZ references a variable, no wizard field added",
+		"$TZ: no fnOS equivalent, set it in .env or replace it",
+	}
+	if !reflect.DeepEqual(result.Unmapped, expectedUnmapped) {
+		t.Errorf("unmapped = %v", result.Unmapped)
+	}
+
+	umbrelApp := []byte(`
+id: whoami
+name: Whoami
+version: "1.10"
+port: 8090
+gallery: [1.jpg]
+`)
+	umbrelCompose := []byte(`
+services:
+  app_proxy:
+    environment:
+      APP_HOST: whoami_web_1
+      APP_PORT: 80
+  web:
+    image: traefik/whoami:v1.10
+    volumes:
+      - ${APP_DATA_DIR}/data:/data
+    environment:
+      - SEED=${APP_SEED}
+`)
+	result, err = Import("umbrel", ImportSource{Compose: umbrelCompose, Metadata: umbrelApp})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if _, ok := result.Compose.Services["app_proxy"]; ok {
+		t.Error("expected app_proxy to be removed")
+	}
+	web := result.Compose.Services["web"]
+	if !reflect.DeepEqual(web.Ports, []string{"8090:80"}) || !reflect.DeepEqual(web.Volumes, []string{"${TRIM_PKGVAR}/data:/data"}) {
+		t.Errorf("ports = %v, volumes = %v", web.Ports, web.Volumes)
+	}
+	if result.IconURL != umbrelGallery+"/whoami/icon.svg" {
+		t.Errorf("IconURL = %q", result.IconURL)
+	}
+	expectedUnmapped = []string{"gallery: no fnOS equivalent", "$APP_SEED: no fnOS equivalent, set it in .env or replace it"}
+	if !reflect.DeepEqual(result.Unmapped, expectedUnmapped) {
+		t.Errorf("unmapped = %v, expected %v", result.Unmapped, expectedUnmapped)
+	}
+
+	templates := []byte(`{"version": "2", "templates": [
+  {"type": 1, "title": "Whoami", "image": "traefik/whoami", "ports": ["8080:80/tcp"],
+   "volumes": [{"container": "/data"}],
+   "env": [{"name": "NAME", "label": "Name", "default": "demo"}, {"name": "FIXED", "preset": true, "default": "1"}]},
+  {"type": 3, "title": "Stack", "repository": {"url": "https://example.com/repo", "stackfile": "compose.yml"}}
+]}`)
+	if _, err := Import("portainer", ImportSource{Metadata: templates}); err == nil {
+		t.Error("expected error when several templates match")
+	}
+	if _, err := Import("portainer", ImportSource{Metadata: templates, Template: "stack"}); err == nil {
+		t.Error("expected error for a stack template without compose file")
+	}
+	result, err = Import("portainer", ImportSource{Metadata: templates, Template: "whoami"})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	container := result.Compose.Services["whoami"]
+	expectedEnv = []string{"NAME=${wizard_name}", "FIXED=1"}
+	if !reflect.DeepEqual(container.Environment, expectedEnv) || !reflect.DeepEqual(container.Volumes, []string{"${TRIM_PKGVAR}/data:/data"}) {
+		t.Errorf("environment = %v, volumes = %v", container.Environment, container.Volumes)
+	}
+
+	if _, err := Import("unraid", ImportSource{}); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ApplyProfiles removes the services that are not enabled by the active profiles
+// Services without profiles are always enabled, active defaults to
+// x-fnpack.profiles when empty
+// The profiles key is removed from the remaining services, docker compose on
+// fnOS runs without --profile and would skip them otherwise
+// Returns the content and the sorted names of the removed services, and an
+// error if a remaining service depends on a removed one
+func ApplyProfiles(data []byte, active []string) ([]byte, []string, error) {
+	var project map[string]interface{}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compose yaml: %w", err)
+	}
+
+	if len(active) == 0 {
+		xfnpack, _ := project["x-fnpack"].(map[string]interface{})
+		list, _ := xfnpack["profiles"].([]interface{})
+		for _, item := range list {
+			active = append(active, fmt.Sprint(item))
+		}
+	}
+	enabled := make(map[string]bool)
+	for _, profile := range active {
+		enabled[profile] = true
+	}
+
+	services, _ := project["services"].(map[string]interface{})
+	var dropped []string
+	for name, s := range services {
+		service, _ := s.(map[string]interface{})
+		profiles, ok := service["profiles"].([]interface{})
+		if !ok {
+			continue
+		}
+		delete(service, "profiles")
+
+		keep := enabled["*"]
+		for _, profile := range profiles {
+			if enabled[fmt.Sprint(profile)] {
+				keep = true
+			}
+		}
+		if !keep {
+			delete(services, name)
+			dropped = append(dropped, name)
+		}
+	}
+	sort.Strings(dropped)
+
+	if len(dropped) == 0 {
+		out, err := yaml.Marshal(project)
+		return out, nil, err
+	}
+
+	var dangling []string
+	for _, name := range sortedKeys(services) {
+		service, _ := services[name].(map[string]interface{})
+		for _, dependency := range dependencyNames(service["depends_on"]) {
+			if _, ok := services[dependency]; !ok && containsString(dropped, dependency) {
+				dangling = append(dangling, fmt.Sprintf("service %s depends on %s, which is not in the active profiles", name, dependency))
+			}
+		}
+	}
+	if len(dangling) > 0 {
+		return nil, dropped, fmt.Errorf("%s", strings.Join(dangling, "; "))
+	}
+
+	out, err := yaml.Marshal(project)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal compose yaml: %w", err)
+	}
+	return out, dropped, nil
+}
+
+// dependencyNames returns the service names of a depends_on list or map
+func dependencyNames(dependsOn interface{}) []string {
+	var names []string
+	switch v := dependsOn.(type) {
+	case []interface{}:
+		for _, item := range v {
+			names = append(names, fmt.Sprint(item))
+		}
+	case map[string]interface{}:
+		names = sortedKeys(v)
+	}
+	return names
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
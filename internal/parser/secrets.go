@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretsDir is the directory secret files are written to on fnOS
+const SecretsDir = "${TRIM_PKGVAR}/secrets"
+
+// SecretFileEnvLabel is the service label that overrides whether the image
+// reads "*_FILE" environment variables ("true" or "false")
+const SecretFileEnvLabel = "com.fnpack.secret-file-env"
+
+// fileEnvImages are the images known to read "<VAR>_FILE" for their variables
+var fileEnvImages = []string{
+	"postgres",
+	"mysql",
+	"mariadb",
+	"mongo",
+	"wordpress",
+	"nextcloud",
+	"bitnami/",
+}
+
+// Secret is a wizard field whose value is passed to the containers as a file
+type Secret struct {
+	// Name is the secret name, the field without the "wizard_" prefix
+	Name string
+
+	// Field is the wizard field holding the value
+	Field string
+}
+
+// SecretName returns the secret name of a wizard field
+func SecretName(field string) string {
+	return strings.TrimPrefix(field, "wizard_")
+}
+
+// SecretPath returns the file a secret is written to on fnOS
+func SecretPath(name string) string {
+	return SecretsDir + "/" + name
+}
+
+// ApplySecrets moves the secret wizard values out of the service environments
+// An entry that is exactly the wizard variable (KEY=${wizard_x}) becomes
+// KEY_FILE=/run/secrets/<name> when the image supports it, and the secret is
+// added to the service and to the top-level secrets with a file source
+// Returns the content and warnings for values left in the environment and
+// for secrets no service uses
+func ApplySecrets(data []byte, secrets []Secret) ([]byte, []string, error) {
+	var project map[string]interface{}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compose yaml: %w", err)
+	}
+
+	var warnings []string
+	used := make(map[string]bool)
+	services, _ := project["services"].(map[string]interface{})
+
+	for _, name := range sortedKeys(services) {
+		service, _ := services[name].(map[string]interface{})
+		if service == nil {
+			continue
+		}
+		fileEnv := supportsFileEnv(service)
+
+		for _, secret := range secrets {
+			exact := regexp.MustCompile(`^\$(\{` + regexp.QuoteMeta(secret.Field) + `\}|` + regexp.QuoteMeta(secret.Field) + `)$`)
+			moved := false
+
+			entries, isList := keyValues(service["environment"], false)
+			for i, entry := range entries {
+				value, ok := entry.value.(string)
+				if !ok || !referencesVariable(value, secret.Field) {
+					continue
+				}
+				used[secret.Name] = true
+
+				switch {
+				case !exact.MatchString(value):
+					warnings = append(warnings, fmt.Sprintf("service %s: %s embeds secret %s in a larger value, it stays in the environment", name, entry.key, secret.Field))
+				case !fileEnv:
+					warnings = append(warnings, fmt.Sprintf("service %s: image %v is not known to read %s_FILE, secret %s stays in the environment (set label %s=true to override)", name, service["image"], entry.key, secret.Field, SecretFileEnvLabel))
+				default:
+					entries[i] = keyValue{entry.key + "_FILE", "/run/secrets/" + secret.Name}
+					moved = true
+				}
+			}
+			if moved {
+				service["environment"] = buildKeyValues(entries, isList)
+				service["secrets"] = appendSecret(service["secrets"], secret.Name)
+			}
+
+			// References outside the environment (command, healthcheck, ...) count as use
+			if !used[secret.Name] {
+				out, err := yaml.Marshal(service)
+				if err == nil && referencesVariable(string(out), secret.Field) {
+					used[secret.Name] = true
+				}
+			}
+		}
+	}
+
+	for _, secret := range secrets {
+		if !used[secret.Name] {
+			warnings = append(warnings, fmt.Sprintf("secret wizard field %s is not used by any service", secret.Field))
+		}
+	}
+
+	// Add the file sources of the moved secrets, keep declared ones
+	declared, _ := project["secrets"].(map[string]interface{})
+	for _, name := range sortedKeys(services) {
+		service, _ := services[name].(map[string]interface{})
+		for _, secretName := range serviceSecretNames(service["secrets"]) {
+			if _, ok := declared[secretName]; ok {
+				continue
+			}
+			for _, secret := range secrets {
+				if secret.Name == secretName {
+					if declared == nil {
+						declared = make(map[string]interface{})
+					}
+					declared[secretName] = map[string]interface{}{"file": SecretPath(secretName)}
+				}
+			}
+		}
+	}
+	if declared != nil {
+		project["secrets"] = declared
+	}
+
+	// Declared secrets no service mounts are likely leftovers
+	for _, secretName := range sortedKeys(declared) {
+		mounted := false
+		for _, s := range services {
+			service, _ := s.(map[string]interface{})
+			if containsString(serviceSecretNames(service["secrets"]), secretName) {
+				mounted = true
+			}
+		}
+		if !mounted {
+			warnings = append(warnings, fmt.Sprintf("secret %s is declared but not used by any service", secretName))
+		}
+	}
+
+	out, err := yaml.Marshal(project)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal compose yaml: %w", err)
+	}
+	return out, warnings, nil
+}
+
+// referencesVariable reports whether s references the variable name
+func referencesVariable(s, name string) bool {
+	return regexp.MustCompile(`\$\{?` + regexp.QuoteMeta(name) + `\b`).MatchString(s)
+}
+
+// supportsFileEnv reports whether the service image reads "*_FILE" variables
+// The SecretFileEnvLabel label takes precedence over the known images
+func supportsFileEnv(service map[string]interface{}) bool {
+	labels, _ := keyValues(service["labels"], false)
+	for _, label := range labels {
+		if label.key == SecretFileEnvLabel {
+			return fmt.Sprint(label.value) == "true"
+		}
+	}
+
+	_, image := extractImageInfo(fmt.Sprint(service["image"]))
+	repository, _, _ := strings.Cut(fmt.Sprint(service["image"]), ":")
+	for _, known := range fileEnvImages {
+		if strings.HasSuffix(known, "/") {
+			if strings.Contains(repository, known) {
+				return true
+			}
+		} else if image == known {
+			return true
+		}
+	}
+	return false
+}
+
+// appendSecret adds a secret to a service secrets list unless already present
+func appendSecret(secrets interface{}, name string) interface{} {
+	list, _ := secrets.([]interface{})
+	if containsString(serviceSecretNames(list), name) {
+		return list
+	}
+	return append(list, name)
+}
+
+// serviceSecretNames returns the secret names of a service secrets list
+// Entries are names or objects with a source
+func serviceSecretNames(secrets interface{}) []string {
+	list, _ := secrets.([]interface{})
+	var names []string
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			names = append(names, v)
+		case map[string]interface{}:
+			names = append(names, fmt.Sprint(v["source"]))
+		}
+	}
+	return names
+}
@@ -7,6 +7,46 @@ type XFnpack struct {
 	// Manifest contains app metadata as YAML object, converted to key=value format
 	Manifest map[string]interface{} `yaml:"manifest,omitempty"`
 
+	// Icon is the icon file path relative to the input directory
+	// Overrides the icon.* / logo.* lookup
+	Icon string `yaml:"icon,omitempty"`
+
+	// IconStyle controls how the icon is processed before it is resized
+	IconStyle IconStyle `yaml:"icon_style,omitempty"`
+
+	// VersionFrom derives the version instead of manifest.version:
+	// "git-tag", "image-tag" or "file" (VERSION file in the input directory)
+	VersionFrom string `yaml:"version_from,omitempty"`
+
+	// Changelog configures how the manifest changelog is filled when not set
+	Changelog ChangelogConfig `yaml:"changelog,omitempty"`
+
+	// I18n configures the locales of localized manifest, wizard and UI strings
+	I18n I18nConfig `yaml:"i18n,omitempty"`
+
+	// UI lists the services that get a desktop launcher entry
+	// Each item is either a service name or a full UIEntry object
+	UI []UIEntry `yaml:"ui,omitempty"`
+
+	// ComposeFiles lists overlay compose files merged on top of this file,
+	// relative to this file (like repeated "docker compose -f")
+	ComposeFiles []string `yaml:"compose_files,omitempty"`
+
+	// Profiles are the compose profiles enabled in the package, services of
+	// other profiles are dropped (overridden by --profile)
+	Profiles []string `yaml:"profiles,omitempty"`
+
+	// Include lists extra files copied from the input directory into the package
+	// Each item is "<glob>", "<glob> -> <destination>" or an IncludeRule object
+	Include []IncludeRule `yaml:"include,omitempty"`
+
+	// Lifecycle enables generated behaviors of the lifecycle scripts
+	Lifecycle LifecycleConfig `yaml:"lifecycle,omitempty"`
+
+	// Hooks are shell snippets run around the body of the cmd scripts,
+	// keyed by script name (main, install_init, install_callback, ...)
+	Hooks map[string]ScriptHooks `yaml:"hooks,omitempty"`
+
 	// Files contains all file paths and their content (multi-line text)
 	// Key is the file path (e.g., "wizard/install", "app/ui/config", "config/custom")
 	// Value is the file content as string
@@ -18,6 +58,9 @@ type XFnpack struct {
 
 // ComposeFile represents a docker-compose.yaml file with x-fnpack extension
 type ComposeFile struct {
+	// Name is the compose project name
+	Name string `yaml:"name,omitempty"`
+
 	// XFnpack contains the fnOS app configuration
 	XFnpack XFnpack `yaml:"x-fnpack,omitempty"`
 
@@ -64,7 +107,7 @@ type Service struct {
 	DependsOn []string `yaml:"depends_on,omitempty"`
 
 	// Labels is the map of labels
-	Labels map[string]string `yaml:"labels,omitempty"`
+	Labels Labels `yaml:"labels,omitempty"`
 
 	// Command is the container command
 	Command interface{} `yaml:"command,omitempty"`
@@ -124,4 +167,144 @@ type Variables struct {
 	// ImageName is the image name without org and tag
 	// e.g., "lobe-chat" from "lobehub/lobe-chat:latest"
 	ImageName string
+
+	// ImageTag is the tag of the docker image, empty if not specified
+	// e.g., "1.2.3" from "lobehub/lobe-chat:1.2.3"
+	ImageTag string
+
+	// Extra contains user-defined variables (--var KEY=VALUE), replaced as ${KEY}
+	Extra map[string]string
+}
+
+// ChangelogConfig defines the changelog source of x-fnpack.changelog
+type ChangelogConfig struct {
+	// From is the changelog source: "file" (default) or "git"
+	From string `yaml:"from,omitempty"`
+
+	// File is the keep-a-changelog file in the input directory (default: CHANGELOG.md)
+	File string `yaml:"file,omitempty"`
+
+	// MaxLength is the maximum changelog length in characters (default: 1000)
+	MaxLength int `yaml:"max_length,omitempty"`
+}
+
+// I18nConfig defines the locales of x-fnpack.i18n
+type I18nConfig struct {
+	// DefaultLocale is used for the unsuffixed values and as fallback (default: zh)
+	DefaultLocale string `yaml:"default_locale,omitempty"`
+
+	// Locales lists the locales every localized string must provide
+	// Empty means every locale used by any localized string
+	Locales []string `yaml:"locales,omitempty"`
+}
+
+// IconStyle defines the icon processing options of x-fnpack.icon_style
+// The zero value keeps the icon as-is (centered on a transparent square)
+type IconStyle struct {
+	// Trim removes transparent borders before the icon is squared
+	Trim bool `yaml:"trim,omitempty"`
+
+	// Padding is the margin around the icon, in percent of the icon size
+	Padding float64 `yaml:"padding,omitempty"`
+
+	// Radius is the corner radius of the rounded mask, in percent of the icon size
+	Radius float64 `yaml:"radius,omitempty"`
+
+	// Background is a solid color ("#1e88e5") or a vertical gradient of
+	// two comma-separated colors ("#1e88e5,#0d47a1"); empty means transparent
+	Background string `yaml:"background,omitempty"`
+
+	// Sharpen is the sigma of the sharpen pass after downscaling, 0 disables it
+	Sharpen float64 `yaml:"sharpen,omitempty"`
+}
+
+// Labels is the map of service labels
+// Accepts both the map form and the "key=value" list form of docker-compose
+type Labels map[string]string
+
+// UIEntry describes a desktop launcher entry for a web-facing service
+// Entries come from x-fnpack.ui or from com.fnpack.ui.* service labels
+type UIEntry struct {
+	// Name is the entry identifier used in the entry key and icon file names
+	// Defaults to the service name
+	Name string `yaml:"name,omitempty"`
+
+	// Service is the compose service the entry points to
+	Service string `yaml:"service,omitempty"`
+
+	// Title is the launcher title shown on the fnOS desktop
+	// Defaults to the entry name
+	Title string `yaml:"-"`
+
+	// Titles contains the localized titles when title is a {locale: text} map
+	Titles map[string]string `yaml:"-"`
+
+	// Type is the entry mode: "port", "path" or "iframe" (default: port)
+	Type string `yaml:"type,omitempty"`
+
+	// Protocol is the URL scheme, "http" or "https" (default: http)
+	Protocol string `yaml:"protocol,omitempty"`
+
+	// Port is the host port to open
+	// Defaults to the first host port of the service
+	Port string `yaml:"port,omitempty"`
+
+	// Path is the URL path to open
+	// Defaults to "/" for port and iframe entries, "/<name>/" for path entries
+	Path string `yaml:"path,omitempty"`
+
+	// CheckBasePath requires the service to have an environment variable
+	// whose value matches Path (path entries only)
+	CheckBasePath bool `yaml:"check_base_path,omitempty"`
+
+	// Icon is the icon file for this entry, relative to the input directory
+	// Empty means the application icon is used
+	Icon string `yaml:"icon,omitempty"`
+}
+
+// IncludeRule copies the input files matching a glob pattern into the package
+type IncludeRule struct {
+	// From is the glob pattern relative to the input directory, "**" matches
+	// any number of directories (e.g., "conf/**")
+	From string `yaml:"from"`
+
+	// To is the destination relative to the package root
+	// A destination ending with "/" is a directory receiving the matched files
+	// relative to the static prefix of the pattern, otherwise it is a file name
+	// and the pattern must match a single file
+	// Defaults to "app/docker/", next to the compose file, keeping the full path
+	To string `yaml:"to,omitempty"`
+}
+
+// LifecycleConfig defines the opt-in behaviors of x-fnpack.lifecycle
+// Each behavior adds a generated section to a lifecycle script, in front of
+// the default or user-supplied script body
+type LifecycleConfig struct {
+	// Backup archives the data directories in upgrade_init, upgrade_callback
+	// restores them when the migration fails
+	Backup bool `yaml:"backup,omitempty"`
+
+	// BackupPaths are the directories to archive (default: the bind mount
+	// sources under ${TRIM_PKGVAR}, or ${TRIM_PKGVAR} itself)
+	BackupPaths []string `yaml:"backup_paths,omitempty"`
+
+	// Migrate is the one-shot service run in upgrade_callback
+	// It is moved to MigrateProfile so docker compose up skips it
+	Migrate string `yaml:"migrate,omitempty"`
+
+	// StopOnUninstall stops the containers in uninstall_init
+	StopOnUninstall bool `yaml:"stop_on_uninstall,omitempty"`
+
+	// Cleanup is the uninstall wizard field that removes the images and
+	// volumes of the app in uninstall_callback when checked
+	Cleanup string `yaml:"cleanup,omitempty"`
+}
+
+// ScriptHooks defines the snippets of an x-fnpack.hooks entry
+type ScriptHooks struct {
+	// Pre runs before the script body, after the generated sections
+	Pre string `yaml:"pre,omitempty"`
+
+	// Post runs after the script body when it exits successfully
+	Post string `yaml:"post,omitempty"`
 }
@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UI label keys recognized on services
+// A service labeled com.fnpack.ui=true gets its own desktop launcher entry,
+// the com.fnpack.ui.* labels override the entry fields
+const (
+	LabelUI         = "com.fnpack.ui"
+	LabelUIName     = "com.fnpack.ui.name"
+	LabelUITitle    = "com.fnpack.ui.title"
+	LabelUIType     = "com.fnpack.ui.type"
+	LabelUIProtocol = "com.fnpack.ui.protocol"
+	LabelUIPort     = "com.fnpack.ui.port"
+	LabelUIPath     = "com.fnpack.ui.path"
+	LabelUIIcon     = "com.fnpack.ui.icon"
+
+	LabelUICheckBasePath = "com.fnpack.ui.check_base_path"
+)
+
+// UI entry modes
+const (
+	// UIModePort opens protocol://<nas>:<port><path>
+	UIModePort = "port"
+
+	// UIModePath opens <path> through the NAS web portal, without a port
+	UIModePath = "path"
+
+	// UIModeIframe embeds the app in an fnOS desktop window
+	UIModeIframe = "iframe"
+)
+
+// UnmarshalYAML decodes labels from either a map or a "key=value" list
+func (l *Labels) UnmarshalYAML(value *yaml.Node) error {
+	labels := make(Labels)
+
+	switch value.Kind {
+	case yaml.MappingNode:
+		var m map[string]string
+		if err := value.Decode(&m); err != nil {
+			return err
+		}
+		for k, v := range m {
+			labels[k] = v
+		}
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		for _, item := range list {
+			key, val, _ := strings.Cut(item, "=")
+			labels[key] = val
+		}
+	default:
+		return fmt.Errorf("labels must be a map or a list, got %s", value.Tag)
+	}
+
+	*l = labels
+	return nil
+}
+
+// UnmarshalYAML decodes a UI entry from either a service name or an object
+func (e *UIEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		e.Service = value.Value
+		return nil
+	}
+
+	// Decode through an alias type to avoid recursing into this method
+	type rawEntry UIEntry
+	var raw rawEntry
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*e = UIEntry(raw)
+
+	// The title is either a plain string or a {locale: text} map
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value != "title" {
+			continue
+		}
+		titleNode := value.Content[i+1]
+		if titleNode.Kind == yaml.MappingNode {
+			return titleNode.Decode(&e.Titles)
+		}
+		return titleNode.Decode(&e.Title)
+	}
+
+	return nil
+}
+
+// MarshalYAML encodes a UI entry as an object, the title follows the service
+// as a plain string or a {locale: text} map
+func (e UIEntry) MarshalYAML() (interface{}, error) {
+	// Encode through an alias type to avoid recursing into this method
+	type rawEntry UIEntry
+	node := &yaml.Node{}
+	if err := node.Encode(rawEntry(e)); err != nil {
+		return nil, err
+	}
+
+	var title interface{}
+	switch {
+	case len(e.Titles) > 0:
+		title = e.Titles
+	case e.Title != "":
+		title = e.Title
+	default:
+		return node, nil
+	}
+	titleNode := &yaml.Node{}
+	if err := titleNode.Encode(title); err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; key == "name" || key == "service" {
+			pos = i + 2
+		}
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: "title"}
+	content := append([]*yaml.Node{}, node.Content[:pos]...)
+	content = append(content, keyNode, titleNode)
+	node.Content = append(content, node.Content[pos:]...)
+	return node, nil
+}
+
+// ExtractUIEntries collects the desktop launcher entries of the compose file
+// Entries listed in x-fnpack.ui come first (in order), followed by services
+// labeled com.fnpack.ui=true (sorted by name). Missing fields are filled from
+// the service labels and then from defaults.
+func ExtractUIEntries(compose *ComposeFile) []UIEntry {
+	var entries []UIEntry
+	seen := make(map[string]bool)
+
+	defaultLocale := compose.XFnpack.I18n.Default()
+	for _, entry := range compose.XFnpack.UI {
+		if entry.Service == "" {
+			entry.Service = entry.Name
+		}
+		if entry.Title == "" && len(entry.Titles) > 0 {
+			entry.Title = ResolveLocale(entry.Titles, defaultLocale, defaultLocale)
+		}
+		entries = append(entries, completeUIEntry(entry, compose.Services[entry.Service]))
+		seen[entry.Service] = true
+	}
+
+	serviceNames := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	for _, name := range serviceNames {
+		service := compose.Services[name]
+		if seen[name] || !isTrueLabel(service.Labels[LabelUI]) {
+			continue
+		}
+		entries = append(entries, completeUIEntry(UIEntry{Service: name}, service))
+	}
+
+	return entries
+}
+
+// completeUIEntry fills empty entry fields from service labels and defaults
+func completeUIEntry(entry UIEntry, service Service) UIEntry {
+	fill := func(field *string, label, fallback string) {
+		if *field != "" {
+			return
+		}
+		if value := service.Labels[label]; value != "" {
+			*field = value
+			return
+		}
+		*field = fallback
+	}
+
+	fill(&entry.Name, LabelUIName, entry.Service)
+	fill(&entry.Title, LabelUITitle, entry.Name)
+	fill(&entry.Type, LabelUIType, UIModePort)
+	fill(&entry.Icon, LabelUIIcon, "")
+
+	if !entry.CheckBasePath {
+		entry.CheckBasePath = isTrueLabel(service.Labels[LabelUICheckBasePath])
+	}
+
+	// Path entries are served by the NAS web portal and have no port or protocol
+	if entry.Type == UIModePath {
+		fill(&entry.Path, LabelUIPath, "/"+entry.Name+"/")
+		return entry
+	}
+
+	fill(&entry.Protocol, LabelUIProtocol, "http")
+	fill(&entry.Path, LabelUIPath, "/")
+
+	firstPort := ""
+	if len(service.Ports) > 0 {
+		firstPort = extractHostPort(service.Ports[0])
+	}
+	fill(&entry.Port, LabelUIPort, firstPort)
+
+	return entry
+}
+
+// isTrueLabel reports whether a label value means "enabled"
+func isTrueLabel(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "1", "on":
+		return true
+	}
+	return false
+}